@@ -0,0 +1,997 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	flushEvery  = 100
+	defaultTopN = 10
+
+	defaultSellCount   = 10
+	defaultInvestCount = 10
+)
+
+type apiError struct {
+	Error string `json:"error"`
+	Code  string `json:"code"`
+}
+
+func newAPIError(status int, msg string) apiError {
+	return apiError{
+		Error: msg,
+		Code:  strings.ToLower(strings.ReplaceAll(http.StatusText(status), " ", "_")),
+	}
+}
+
+func writeJSONError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(newAPIError(status, msg))
+}
+
+var modFields = map[string]func(*Mod) interface{}{
+	"uid":           func(m *Mod) interface{} { return m.Uid },
+	"slot":          func(m *Mod) interface{} { return m.Slot },
+	"set":           func(m *Mod) interface{} { return m.Set },
+	"level":         func(m *Mod) interface{} { return m.Level },
+	"pips":          func(m *Mod) interface{} { return m.Pips },
+	"cost":          func(m *Mod) interface{} { return m.UpgradeCost },
+	"score":         func(m *Mod) interface{} { return presentScore(m.TotalScore) },
+	"grade":         func(m *Mod) interface{} { return m.Grade },
+	"survivability": func(m *Mod) interface{} { return presentScore(m.Survivability) },
+	"character":     func(m *Mod) interface{} { return m.CharacterName },
+	"locked":        func(m *Mod) interface{} { return m.Locked },
+	"primary":       func(m *Mod) interface{} { return m.PrimaryStat },
+	"secondaries":   func(m *Mod) interface{} { return m.SecondaryStats },
+	"speed":         func(m *Mod) interface{} { return m.secondaryValue("Speed") },
+}
+
+func parseFields(raw string) ([]string, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	var fields []string
+	for _, f := range strings.Split(raw, ",") {
+		f = strings.ToLower(strings.TrimSpace(f))
+		if _, ok := modFields[f]; !ok {
+			return nil, fmt.Errorf("unknown field %q", f)
+		}
+		fields = append(fields, f)
+	}
+
+	return fields, nil
+}
+
+func projectMod(m *Mod, fields []string) map[string]interface{} {
+	projected := make(map[string]interface{}, len(fields))
+	for _, f := range fields {
+		projected[f] = modFields[f](m)
+	}
+	return projected
+}
+
+func setCacheControl(w http.ResponseWriter, maxAge time.Duration) {
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds())))
+}
+
+// setResultHeaders lets clients cache a scrape briefly and marks results
+// that stopped at -max-pages or came from a stale cache entry, since the
+// JSON bodies have nowhere else to say so.
+func setResultHeaders(w http.ResponseWriter, res *ScrapeResult) {
+	setCacheControl(w, *resultMaxAge)
+	if res.Partial {
+		w.Header().Set("X-Partial-Result", "true")
+	}
+	if res.Stale {
+		w.Header().Set("X-Stale-Result", "true")
+	}
+}
+
+// prefersJSON reports whether the Accept header ranks application/json
+// above text/html. Missing q-values count as 1 and wildcards only match the
+// media types they cover, so a bare "*/*" still gets HTML.
+func prefersJSON(accept string) bool {
+	var jsonQ, htmlQ float64
+
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		mediaType := strings.ToLower(strings.TrimSpace(params[0]))
+
+		q := 1.0
+		for _, param := range params[1:] {
+			key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if ok && strings.TrimSpace(key) == "q" {
+				if v, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+					q = v
+				}
+			}
+		}
+
+		switch mediaType {
+		case "application/json":
+			jsonQ = math.Max(jsonQ, q)
+		case "text/html", "text/*", "*/*":
+			htmlQ = math.Max(htmlQ, q)
+		}
+	}
+
+	return jsonQ > htmlQ
+}
+
+func scrapeErrorStatus(err error) int {
+	if errors.Is(err, errCircuitOpen) || errors.Is(err, errRateLimited) || errors.Is(err, errTooBusy) {
+		return http.StatusServiceUnavailable
+	}
+	if errors.Is(err, errUserNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusBadGateway
+}
+
+func writeScrapeError(w http.ResponseWriter, err error) {
+	writeJSONError(w, scrapeErrorStatus(err), err.Error())
+}
+
+// normalizeStatType accepts "Offense%" as well as "Offense %" so callers
+// don't have to get the space before the percent sign right.
+func normalizeStatType(raw string) string {
+	statType := strings.TrimSpace(raw)
+	if strings.HasSuffix(statType, "%") {
+		statType = strings.TrimSpace(strings.TrimSuffix(statType, "%")) + " %"
+	}
+	return statType
+}
+
+func topByStat(mods []*Mod, statType string, n int) []*Mod {
+	var top []*Mod
+	for _, m := range mods {
+		if m.secondary(statType) != nil {
+			top = append(top, m)
+		}
+	}
+
+	sort.SliceStable(top, func(i, j int) bool {
+		return top[i].secondaryValue(statType) > top[j].secondaryValue(statType)
+	})
+
+	if len(top) > n {
+		top = top[:n]
+	}
+
+	return top
+}
+
+func wantsPretty(r *http.Request) bool {
+	pretty, _ := strconv.ParseBool(r.URL.Query().Get("pretty"))
+	return pretty
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}, pretty bool) error {
+	w.Header().Set("Content-Type", "application/json")
+
+	if !pretty {
+		return json.NewEncoder(w).Encode(v)
+	}
+
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// writeModsJSON streams mods one at a time unless pretty output is asked
+// for, which is meant for reading in a browser and is built in one go.
+func writeModsJSON(w http.ResponseWriter, mods []*Mod, fields []string, pretty bool) error {
+	if pretty {
+		values := make([]interface{}, len(mods))
+		for i, m := range mods {
+			values[i] = m
+			if len(fields) > 0 {
+				values[i] = projectMod(m, fields)
+			}
+		}
+		return writeJSON(w, values, true)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	flusher, canFlush := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+
+	for i, m := range mods {
+		if i > 0 {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+
+		var v interface{} = m
+		if len(fields) > 0 {
+			v = projectMod(m, fields)
+		}
+
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+
+		if canFlush && (i+1)%flushEvery == 0 {
+			flusher.Flush()
+		}
+	}
+
+	_, err := io.WriteString(w, "]")
+	return err
+}
+
+// writeModsNDJSON writes one mod per line, flushing as it goes when w is
+// an http.ResponseWriter that supports it.
+func writeModsNDJSON(w io.Writer, mods []*Mod, fields []string) error {
+	flusher, canFlush := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+
+	for i, m := range mods {
+		var v interface{} = m
+		if len(fields) > 0 {
+			v = projectMod(m, fields)
+		}
+
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+
+		if canFlush && (i+1)%flushEvery == 0 {
+			flusher.Flush()
+		}
+	}
+
+	return nil
+}
+
+func apiMods(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r)
+	user := userParam(r.URL.Query())
+
+	if user == "" {
+		writeJSONError(w, http.StatusBadRequest, "missing u parameter")
+		return
+	}
+
+	fields, err := parseFields(r.URL.Query().Get("fields"))
+
+	if err != nil {
+		logger.Warn("Bad fields parameter", "err", err)
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "ndjson" && format != "swgohgg" {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("unknown format %q, want json, ndjson or swgohgg", format))
+		return
+	}
+
+	summaryOnly := false
+	if raw := r.URL.Query().Get("summary"); raw != "" {
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("bad summary %q", raw))
+			return
+		}
+		summaryOnly = v
+	}
+
+	filter, err := parseModFilter(r.URL.Query())
+
+	if err != nil {
+		logger.Warn("Bad filter", "err", err)
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	opts, err := scoringOptions(r.URL.Query())
+
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	res, err := getMods(withLogger(r.Context(), logger), user, opts)
+
+	if err != nil {
+		writeScrapeError(w, err)
+		return
+	}
+
+	setResultHeaders(w, res)
+
+	if summaryOnly {
+		err = writeJSON(w, summarizeMods(filter.apply(res.Mods), res.SecondaryScoreMap, opts), wantsPretty(r))
+	} else if format == "ndjson" {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		err = writeModsNDJSON(w, filter.apply(res.Mods), fields)
+	} else if format == "swgohgg" {
+		err = writeJSON(w, exportGGMods(logger, filter.apply(res.Mods)), wantsPretty(r))
+	} else {
+		err = writeModsJSON(w, filter.apply(res.Mods), fields, wantsPretty(r))
+	}
+
+	if err != nil {
+		logger.Error("Failed to write mods", "user", user, "err", err)
+	}
+}
+
+func apiTop(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r)
+	q := r.URL.Query()
+	user := userParam(q)
+	statType := normalizeStatType(q.Get("stat"))
+
+	if user == "" || statType == "" {
+		writeJSONError(w, http.StatusBadRequest, "missing u or stat parameter")
+		return
+	}
+
+	n := defaultTopN
+	if rawN := q.Get("n"); rawN != "" {
+		v, err := strconv.Atoi(rawN)
+		if err != nil || v < 1 {
+			logger.Warn("Bad n parameter", "n", rawN)
+			writeJSONError(w, http.StatusBadRequest, "n must be a positive integer")
+			return
+		}
+		n = v
+	}
+
+	opts, err := scoringOptions(r.URL.Query())
+
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	res, err := getMods(withLogger(r.Context(), logger), user, opts)
+
+	if err != nil {
+		writeScrapeError(w, err)
+		return
+	}
+
+	setResultHeaders(w, res)
+
+	if err := writeModsJSON(w, topByStat(res.Mods, statType, n), nil, wantsPretty(r)); err != nil {
+		logger.Error("Failed to write top mods", "user", user, "err", err)
+	}
+}
+
+func apiTotals(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r)
+	user := userParam(r.URL.Query())
+
+	if user == "" {
+		writeJSONError(w, http.StatusBadRequest, "missing u parameter")
+		return
+	}
+
+	opts, err := scoringOptions(r.URL.Query())
+
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	res, err := getMods(withLogger(r.Context(), logger), user, opts)
+
+	if err != nil {
+		writeScrapeError(w, err)
+		return
+	}
+
+	setResultHeaders(w, res)
+
+	if err := writeJSON(w, equippedTotals(res.Mods), wantsPretty(r)); err != nil {
+		logger.Error("Failed to write totals", "user", user, "err", err)
+	}
+}
+
+func apiStats(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r)
+	user := userParam(r.URL.Query())
+
+	if user == "" {
+		writeJSONError(w, http.StatusBadRequest, "missing u parameter")
+		return
+	}
+
+	opts, err := scoringOptions(r.URL.Query())
+
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	res, err := getMods(withLogger(r.Context(), logger), user, opts)
+
+	if err != nil {
+		writeScrapeError(w, err)
+		return
+	}
+
+	setResultHeaders(w, res)
+
+	if err := writeJSON(w, observedStats(res.Mods), wantsPretty(r)); err != nil {
+		logger.Error("Failed to write stat types", "user", user, "err", err)
+	}
+}
+
+func apiDiff(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r)
+	user := userParam(r.URL.Query())
+
+	if user == "" {
+		writeJSONError(w, http.StatusBadRequest, "missing u parameter")
+		return
+	}
+
+	opts, err := scoringOptions(r.URL.Query())
+
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	res, err := getMods(withLogger(r.Context(), logger), user, opts)
+
+	if err != nil {
+		writeScrapeError(w, err)
+		return
+	}
+
+	if res.Partial {
+		writeJSONError(w, http.StatusConflict, "can't diff a partial scrape, raise -max-pages")
+		return
+	}
+
+	prev, ok := snapshots.previous(user)
+
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, "no earlier scrape of this user to compare against")
+		return
+	}
+
+	// The earlier scrape is scored the same way, so score changes come
+	// from the mods, not the options.
+	prevMods := scoreResult(logger, user, prev.res, opts).Mods
+
+	if err := writeJSON(w, diffMods(snapshot{prev.taken, prevMods}, snapshot{time.Now(), res.Mods}), wantsPretty(r)); err != nil {
+		logger.Error("Failed to write diff", "user", user, "err", err)
+	}
+}
+
+func apiSell(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r)
+	q := r.URL.Query()
+	user := userParam(q)
+
+	if user == "" {
+		writeJSONError(w, http.StatusBadRequest, "missing u parameter")
+		return
+	}
+
+	count := defaultSellCount
+	if rawCount := q.Get("count"); rawCount != "" {
+		v, err := strconv.Atoi(rawCount)
+		if err != nil || v < 1 {
+			logger.Warn("Bad count parameter", "count", rawCount)
+			writeJSONError(w, http.StatusBadRequest, "count must be a positive integer")
+			return
+		}
+		count = v
+	}
+
+	includeEquipped := false
+	if raw := q.Get("includeEquipped"); raw != "" {
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("bad includeEquipped %q", raw))
+			return
+		}
+		includeEquipped = v
+	}
+
+	includeLocked := false
+	if raw := q.Get("includeLocked"); raw != "" {
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("bad includeLocked %q", raw))
+			return
+		}
+		includeLocked = v
+	}
+
+	// Sell candidates are worst first whatever -default-order says.
+	ascending, err := parseOrder(q, "asc")
+
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	opts, err := scoringOptions(q)
+
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	res, err := getMods(withLogger(r.Context(), logger), user, opts)
+
+	if err != nil {
+		writeScrapeError(w, err)
+		return
+	}
+
+	setResultHeaders(w, res)
+
+	candidates := sellCandidates(res.Mods, count, includeEquipped, includeLocked)
+	if !ascending {
+		slices.Reverse(candidates)
+	}
+
+	if err := writeModsJSON(w, candidates, nil, wantsPretty(r)); err != nil {
+		logger.Error("Failed to write sell candidates", "user", user, "err", err)
+	}
+}
+
+func apiInvest(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r)
+	q := r.URL.Query()
+	user := userParam(q)
+
+	if user == "" {
+		writeJSONError(w, http.StatusBadRequest, "missing u parameter")
+		return
+	}
+
+	count := defaultInvestCount
+	if rawCount := q.Get("count"); rawCount != "" {
+		v, err := strconv.Atoi(rawCount)
+		if err != nil || v < 1 {
+			logger.Warn("Bad count parameter", "count", rawCount)
+			writeJSONError(w, http.StatusBadRequest, "count must be a positive integer")
+			return
+		}
+		count = v
+	}
+
+	opts, err := scoringOptions(q)
+
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if opts.Disabled {
+		writeJSONError(w, http.StatusBadRequest, "scoring is disabled")
+		return
+	}
+
+	res, err := getMods(withLogger(r.Context(), logger), user, opts)
+
+	if err != nil {
+		writeScrapeError(w, err)
+		return
+	}
+
+	setResultHeaders(w, res)
+
+	if err := writeJSON(w, investments(res.Mods, count), wantsPretty(r)); err != nil {
+		logger.Error("Failed to write investments", "user", user, "err", err)
+	}
+}
+
+func apiBest(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r)
+	q := r.URL.Query()
+	user := userParam(q)
+
+	if user == "" {
+		writeJSONError(w, http.StatusBadRequest, "missing u parameter")
+		return
+	}
+
+	p, err := lookupProfile(q.Get("profile"))
+
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	strategy := q.Get("strategy")
+	if strategy == "" {
+		strategy = "any"
+	}
+	if !loadoutStrategies[strategy] {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("unknown strategy %q", strategy))
+		return
+	}
+
+	opts, err := scoringOptions(q)
+
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if opts.Disabled {
+		writeJSONError(w, http.StatusBadRequest, "scoring is disabled")
+		return
+	}
+
+	res, err := getMods(withLogger(r.Context(), logger), user, opts)
+
+	if err != nil {
+		writeScrapeError(w, err)
+		return
+	}
+
+	recommended, err := recommendMods(availableMods(res.Mods, q.Get("character")), p, strategy)
+
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	setResultHeaders(w, res)
+
+	if err := writeModsJSON(w, recommended, nil, wantsPretty(r)); err != nil {
+		logger.Error("Failed to write recommended mods", "user", user, "err", err)
+	}
+}
+
+func apiLoadout(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r)
+	q := r.URL.Query()
+	user := userParam(q)
+
+	if user == "" {
+		writeJSONError(w, http.StatusBadRequest, "missing u parameter")
+		return
+	}
+
+	p, err := lookupProfile(q.Get("profile"))
+
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	reqs, err := parseSetRequirements(q.Get("sets"))
+
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	opts, err := scoringOptions(q)
+
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if opts.Disabled {
+		writeJSONError(w, http.StatusBadRequest, "scoring is disabled")
+		return
+	}
+
+	res, err := getMods(withLogger(r.Context(), logger), user, opts)
+
+	if err != nil {
+		writeScrapeError(w, err)
+		return
+	}
+
+	loadout, err := suggestLoadout(availableMods(res.Mods, q.Get("character")), p, reqs)
+
+	if err != nil {
+		writeJSONError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	setResultHeaders(w, res)
+
+	if err := writeJSON(w, loadout, wantsPretty(r)); err != nil {
+		logger.Error("Failed to write loadout", "user", user, "err", err)
+	}
+}
+
+func apiBounds(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r)
+	user := userParam(r.URL.Query())
+
+	if user == "" {
+		writeJSONError(w, http.StatusBadRequest, "missing u parameter")
+		return
+	}
+
+	opts, err := scoringOptions(r.URL.Query())
+
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	res, err := getMods(withLogger(r.Context(), logger), user, opts)
+
+	if err != nil {
+		writeScrapeError(w, err)
+		return
+	}
+
+	setResultHeaders(w, res)
+
+	if err := writeJSON(w, scoringBounds(res.SecondaryScoreMap, opts), wantsPretty(r)); err != nil {
+		logger.Error("Failed to write bounds", "user", user, "err", err)
+	}
+}
+
+// modDetail is one mod with the bounds its secondaries were scored against.
+type modDetail struct {
+	Mod    *Mod         `json:"mod"`
+	Bounds []StatBounds `json:"bounds"`
+}
+
+func apiMod(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r)
+	q := r.URL.Query()
+	user := userParam(q)
+	uid := q.Get("uid")
+
+	if user == "" || uid == "" {
+		writeJSONError(w, http.StatusBadRequest, "missing u or uid parameter")
+		return
+	}
+
+	opts, err := scoringOptions(q)
+
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	res, err := getMods(withLogger(r.Context(), logger), user, opts)
+
+	if err != nil {
+		writeScrapeError(w, err)
+		return
+	}
+
+	i := slices.IndexFunc(res.Mods, func(m *Mod) bool { return m.Uid == uid })
+	if i < 0 {
+		writeJSONError(w, http.StatusNotFound, fmt.Sprintf("no mod %q for %s", uid, user))
+		return
+	}
+	mod := res.Mods[i]
+
+	var bounds []StatBounds
+	for _, b := range scoringBounds(res.SecondaryScoreMap, opts) {
+		for _, stat := range mod.SecondaryStats {
+			if stat.Type == b.Type {
+				bounds = append(bounds, b)
+				break
+			}
+		}
+	}
+
+	setResultHeaders(w, res)
+
+	if err := writeJSON(w, modDetail{mod, bounds}, wantsPretty(r)); err != nil {
+		logger.Error("Failed to write mod", "user", user, "uid", uid, "err", err)
+	}
+}
+
+func apiWhatIf(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r)
+	q := r.URL.Query()
+	user := userParam(q)
+
+	if user == "" {
+		writeJSONError(w, http.StatusBadRequest, "missing u parameter")
+		return
+	}
+
+	stats, err := parseWhatIfStats(q["stat"])
+
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	opts, err := scoringOptions(q)
+
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if opts.Disabled {
+		writeJSONError(w, http.StatusBadRequest, "scoring is disabled")
+		return
+	}
+
+	res, err := getMods(withLogger(r.Context(), logger), user, opts)
+
+	if err != nil {
+		writeScrapeError(w, err)
+		return
+	}
+
+	setResultHeaders(w, res)
+
+	if err := writeJSON(w, scoreWhatIf(stats, res.SecondaryScoreMap, opts), wantsPretty(r)); err != nil {
+		logger.Error("Failed to write what-if score", "user", user, "err", err)
+	}
+}
+
+// writeEvent writes one server-sent event and flushes it to the client.
+func writeEvent(w http.ResponseWriter, event string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data); err != nil {
+		return err
+	}
+
+	w.(http.Flusher).Flush()
+	return nil
+}
+
+type eventsDone struct {
+	Count   int    `json:"count"`
+	Partial bool   `json:"partial"`
+	Stale   bool   `json:"stale"`
+	Mods    []*Mod `json:"mods"`
+}
+
+// apiEvents streams a user's scrape as server-sent events: a progress event
+// per page fetched and once scoring is done, then a done event carrying the
+// filtered mods or an error event. The scrape is cancelled if the client
+// goes away.
+func apiEvents(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r)
+	q := r.URL.Query()
+	user := userParam(q)
+
+	if user == "" {
+		writeJSONError(w, http.StatusBadRequest, "missing u parameter")
+		return
+	}
+
+	if _, ok := w.(http.Flusher); !ok {
+		writeJSONError(w, http.StatusInternalServerError, "streaming unsupported")
+		return
+	}
+
+	filter, err := parseModFilter(q)
+
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	opts, err := scoringOptions(q)
+
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	// Progress is reported from the scrape's own goroutines, so hand it to
+	// this one to do the writing.
+	events := make(chan Progress)
+	var res *ScrapeResult
+	go func() {
+		defer close(events)
+		res, err = getModsWithProgress(withLogger(r.Context(), logger), user, opts, func(p Progress) { events <- p })
+	}()
+
+	for p := range events {
+		if err := writeEvent(w, "progress", p); err != nil {
+			logger.Warn("Failed to write progress", "user", user, "err", err)
+		}
+	}
+
+	if errors.Is(err, context.Canceled) {
+		logger.Info("Client went away, scrape cancelled", "user", user)
+		return
+	}
+
+	if err != nil {
+		writeEvent(w, "error", newAPIError(scrapeErrorStatus(err), err.Error()))
+		return
+	}
+
+	mods := filter.apply(res.Mods)
+	writeEvent(w, "done", eventsDone{len(mods), res.Partial, res.Stale, mods})
+}
+
+func apiGuild(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r)
+	q := r.URL.Query()
+
+	users, err := parseUsers(q.Get("u"))
+
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	pooled := true
+	switch q.Get("pool") {
+	case "", "combined":
+	case "separate":
+		pooled = false
+	default:
+		writeJSONError(w, http.StatusBadRequest, "pool must be combined or separate")
+		return
+	}
+
+	grouped := false
+	switch q.Get("view") {
+	case "", "interleaved":
+	case "grouped":
+		grouped = true
+	default:
+		writeJSONError(w, http.StatusBadRequest, "view must be interleaved or grouped")
+		return
+	}
+
+	opts, err := scoringOptions(q)
+
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	mods, err := getGuildMods(withLogger(r.Context(), logger), users, opts, pooled)
+
+	if err != nil {
+		writeScrapeError(w, err)
+		return
+	}
+
+	if grouped {
+		mods = groupByOwner(mods, users)
+	}
+
+	setCacheControl(w, *resultMaxAge)
+
+	if err := writeModsJSON(w, mods, nil, wantsPretty(r)); err != nil {
+		logger.Error("Failed to write guild mods", "users", users, "err", err)
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// readUsersFile reads one username per line, skipping blank lines, lines
+// starting with # and repeats.
+func readUsersFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var users []string
+	seen := make(map[string]bool)
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		u := normalizeUser(line)
+		if u == "" || seen[u] {
+			continue
+		}
+		seen[u] = true
+		users = append(users, u)
+	}
+
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(users) == 0 {
+		return nil, fmt.Errorf("no users in %s", path)
+	}
+
+	return users, nil
+}
+
+// batchUser scrapes and scores one user, writing their mods to
+// outDir/<user>.ndjson.
+func batchUser(logger *slog.Logger, user, outDir string, opts ScoringOptions) (*ScrapeResult, error) {
+	if strings.ContainsAny(user, `/\`) || user == "." || user == ".." {
+		return nil, fmt.Errorf("bad username %q", user)
+	}
+
+	res, err := getMods(withLogger(context.Background(), logger), user, opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Create(filepath.Join(outDir, user+".ndjson"))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := writeModsNDJSON(f, res.Mods, nil); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return res, f.Close()
+}
+
+// writeGuildTopCSV writes the best n of mods, already pooled and sorted,
+// as CSV with their owners.
+func writeGuildTopCSV(w io.Writer, mods []*Mod, n int) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"rank", "owner", "uid", "slot", "set", "pips", "level", "primary", "score", "character", "secondaries"})
+
+	for _, m := range mods[:min(n, len(mods))] {
+		secondaries := make([]string, len(m.SecondaryStats))
+		for i, s := range m.SecondaryStats {
+			secondaries[i] = fmt.Sprintf("%s %v", s.Type, s.Value)
+		}
+
+		cw.Write([]string{
+			strconv.Itoa(m.Rank),
+			m.Owner,
+			m.Uid,
+			m.Slot,
+			m.Set,
+			strconv.Itoa(m.Pips),
+			strconv.Itoa(m.Level),
+			m.PrimaryStat.Type,
+			formatScore(m.TotalScore),
+			m.CharacterName,
+			strings.Join(secondaries, "; "),
+		})
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeGuildTop pools the users' scrapes, scoring them against the combined
+// population, and writes the best -guild-top to outDir/guild-top.csv.
+func writeGuildTop(users []string, results []*ScrapeResult, outDir string, opts ScoringOptions) error {
+	mods := poolMods(users, results, opts, true)
+
+	f, err := os.Create(filepath.Join(outDir, "guild-top.csv"))
+
+	if err != nil {
+		return err
+	}
+
+	if err := writeGuildTopCSV(f, mods, *guildTop); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// writeOwnerAliases writes the -anonymize-owners pseudonyms and who they
+// stand for to outDir/guild-owners.csv, kept apart from the report so the
+// report can be shared on its own.
+func writeOwnerAliases(users []string, outDir string) error {
+	f, err := os.Create(filepath.Join(outDir, "guild-owners.csv"))
+
+	if err != nil {
+		return err
+	}
+
+	aliases := ownerAliases(users)
+	cw := csv.NewWriter(f)
+	cw.Write([]string{"alias", "user"})
+	for _, u := range users {
+		cw.Write([]string{aliases[u], u})
+	}
+	cw.Flush()
+
+	if err := cw.Error(); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// runBatch scrapes every user in turn, -max-concurrent-scrapes at a time
+// (one when unlimited, to stay polite to swgoh.gg) and starting one at most
+// every -crawl-delay. A failing user is
+// logged and skipped rather than stopping the batch; -guild-top then pools
+// whoever succeeded. It returns how many users failed.
+func runBatch(logger *slog.Logger, users []string, outDir string) int {
+	opts, _ := scoringOptions(nil)
+	workers := max(*maxConcurrentScrapes, 1)
+	start := time.Now()
+
+	jobs := make(chan string)
+	var mu sync.Mutex
+	var failed []string
+	results := make(map[string]*ScrapeResult, len(users))
+
+	var wg sync.WaitGroup
+	for range min(workers, len(users)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for user := range jobs {
+				res, err := batchUser(logger, user, outDir, opts)
+
+				if err != nil {
+					logger.Error("Batch user failed", "user", user, "err", err)
+
+					mu.Lock()
+					failed = append(failed, user)
+					mu.Unlock()
+					continue
+				}
+				logger.Info("Batch user done", "user", user)
+
+				mu.Lock()
+				results[user] = res
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for i, u := range users {
+		if i > 0 {
+			time.Sleep(*crawlDelay)
+		}
+		jobs <- u
+	}
+	close(jobs)
+	wg.Wait()
+
+	if *guildTop > 0 && len(results) > 0 {
+		var pooledUsers []string
+		var pooled []*ScrapeResult
+		for _, u := range users {
+			if res, ok := results[u]; ok {
+				pooledUsers = append(pooledUsers, u)
+				pooled = append(pooled, res)
+			}
+		}
+
+		if err := writeGuildTop(pooledUsers, pooled, outDir, opts); err != nil {
+			logger.Error("Failed to write guild top mods", "err", err)
+		}
+
+		if *anonymizeOwners {
+			if err := writeOwnerAliases(pooledUsers, outDir); err != nil {
+				logger.Error("Failed to write owner aliases", "err", err)
+			}
+		}
+	}
+
+	logger.Info("Batch finished", "users", len(users), "ok", len(users)-len(failed), "failed", len(failed), "failedUsers", failed, "took", time.Since(start), "outDir", outDir)
+
+	return len(failed)
+}
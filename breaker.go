@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var errCircuitOpen = errors.New("swgoh.gg is unavailable, scraping paused")
+
+var upstream = newCircuitBreaker(0, 0)
+
+// circuitBreaker stops calling upstream after threshold consecutive
+// failures. Once cooldown has passed a single probe is let through; a
+// success closes the circuit again, a failure restarts the cooldown.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openedAt  time.Time
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown}
+}
+
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.threshold <= 0 || b.failures < b.threshold {
+		return true
+	}
+
+	if time.Since(b.openedAt) < b.cooldown {
+		return false
+	}
+
+	b.openedAt = time.Now()
+	return true
+}
+
+func (b *circuitBreaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err == nil {
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openedAt = time.Now()
+	}
+}
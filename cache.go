@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+type cacheEntry struct {
+	res        *ScrapeResult
+	fetched    time.Time
+	refreshing bool
+}
+
+// inflightFetch is a scrape of a user in progress, which callers that miss
+// the cache meanwhile wait on rather than scraping again.
+type inflightFetch struct {
+	done chan struct{}
+	res  *ScrapeResult
+	err  error
+}
+
+// scrapeCache keeps raw scrape results per user. Results younger than ttl
+// are served as is. Up to maxStale past that they are still served, marked
+// stale, while a single background refresh replaces them. Past that they
+// are dropped, the next time a result is stored.
+type scrapeCache struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	maxStale time.Duration
+	entries  map[string]*cacheEntry
+	inflight map[string]*inflightFetch
+	fetch    func(context.Context, string, progressFunc) (*ScrapeResult, error)
+	// stored, if set, is called with each scrape as it is stored, or as it
+	// is fetched when there's no ttl to cache it for.
+	stored func(user string, res *ScrapeResult)
+}
+
+var scrapes = newScrapeCache(0, 0, fetchMods)
+
+// cacheStatus says how a scrape was served: fetched for the caller, fresh
+// from the cache, or stale from the cache while it refreshes.
+type cacheStatus string
+
+const (
+	cacheMiss  cacheStatus = "miss"
+	cacheHit   cacheStatus = "hit"
+	cacheStale cacheStatus = "stale"
+)
+
+func newScrapeCache(ttl, maxStale time.Duration, fetch func(context.Context, string, progressFunc) (*ScrapeResult, error)) *scrapeCache {
+	return &scrapeCache{
+		ttl:      ttl,
+		maxStale: maxStale,
+		entries:  make(map[string]*cacheEntry),
+		inflight: make(map[string]*inflightFetch),
+		fetch:    fetch,
+	}
+}
+
+// get returns the scrape for user, how it was served, and any error.
+// progress only hears from scrapes made for this call, not background ones
+// or one it waits on that another caller started.
+func (c *scrapeCache) get(ctx context.Context, user string, progress progressFunc) (*ScrapeResult, cacheStatus, error) {
+	logger := loggerFrom(ctx)
+	if c.ttl <= 0 {
+		res, err := c.fetch(ctx, user, progress)
+		if err == nil && c.stored != nil {
+			c.stored(user, res)
+		}
+		return res, cacheMiss, err
+	}
+
+	c.mu.Lock()
+	e, ok := c.entries[user]
+	if ok {
+		age := time.Since(e.fetched)
+
+		if age < c.ttl {
+			c.mu.Unlock()
+			logger.Info("Cache hit", "user", user, "age", age)
+			return e.res, cacheHit, nil
+		}
+
+		if age < c.ttl+c.maxStale {
+			if !e.refreshing {
+				e.refreshing = true
+				go c.refresh(logger, user)
+			}
+			c.mu.Unlock()
+			logger.Info("Serving stale result", "user", user, "age", age)
+			return e.res, cacheStale, nil
+		}
+	}
+
+	if f, ok := c.inflight[user]; ok {
+		c.mu.Unlock()
+		logger.Info("Waiting on scrape in progress", "user", user)
+
+		select {
+		case <-f.done:
+		case <-ctx.Done():
+			return nil, cacheMiss, ctx.Err()
+		}
+
+		// The caller that started the scrape went away, which says nothing
+		// about this one.
+		if errors.Is(f.err, context.Canceled) && ctx.Err() == nil {
+			return c.get(ctx, user, progress)
+		}
+		return f.res, cacheMiss, f.err
+	}
+
+	f := &inflightFetch{done: make(chan struct{})}
+	c.inflight[user] = f
+	c.mu.Unlock()
+
+	f.res, f.err = c.fetch(ctx, user, progress)
+
+	c.mu.Lock()
+	delete(c.inflight, user)
+	if f.err == nil {
+		c.storeLocked(user, f.res)
+	}
+	c.mu.Unlock()
+	close(f.done)
+
+	return f.res, cacheMiss, f.err
+}
+
+// refresh outlives the request that started it, so it keeps only the
+// request's logger, not its cancellation.
+func (c *scrapeCache) refresh(logger *slog.Logger, user string) {
+	res, err := c.fetch(withLogger(context.Background(), logger), user, nil)
+
+	if err != nil {
+		logger.Warn("Background refresh failed", "user", user, "err", err)
+
+		c.mu.Lock()
+		if e, ok := c.entries[user]; ok {
+			e.refreshing = false
+		}
+		c.mu.Unlock()
+		return
+	}
+
+	c.store(user, res)
+}
+
+func (c *scrapeCache) store(user string, res *ScrapeResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.storeLocked(user, res)
+}
+
+// storeLocked stores res for user and drops the entries too old to serve
+// even stale, so users who stop visiting don't keep their mods in memory.
+func (c *scrapeCache) storeLocked(user string, res *ScrapeResult) {
+	now := time.Now()
+	for u, e := range c.entries {
+		if !e.refreshing && now.Sub(e.fetched) >= c.ttl+c.maxStale {
+			delete(c.entries, u)
+		}
+	}
+
+	c.entries[user] = &cacheEntry{res: res, fetched: now}
+	if c.stored != nil {
+		c.stored(user, res)
+	}
+}
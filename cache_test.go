@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestScrapeCacheCoalescesMisses(t *testing.T) {
+	var fetches atomic.Int32
+	release := make(chan struct{})
+
+	c := newScrapeCache(time.Minute, time.Minute, func(ctx context.Context, user string, progress progressFunc) (*ScrapeResult, error) {
+		fetches.Add(1)
+		<-release
+		return &ScrapeResult{PageCount: 1}, nil
+	})
+
+	const callers = 10
+	results := make([]*ScrapeResult, callers)
+
+	var wg sync.WaitGroup
+	for i := range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], _, _ = c.get(context.Background(), "alice", nil)
+		}()
+	}
+
+	// Let every caller reach the cache before the scrape finishes.
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("got %d fetches for one user, want 1", n)
+	}
+	for i, res := range results {
+		if res == nil || res != results[0] {
+			t.Errorf("caller %d got %p, want the shared result %p", i, res, results[0])
+		}
+	}
+}
+
+func TestScrapeCacheDropsExpiredEntries(t *testing.T) {
+	c := newScrapeCache(time.Millisecond, time.Millisecond, func(ctx context.Context, user string, progress progressFunc) (*ScrapeResult, error) {
+		return &ScrapeResult{}, nil
+	})
+
+	if _, _, err := c.get(context.Background(), "alice", nil); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if _, _, err := c.get(context.Background(), "bob", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries["alice"]; ok {
+		t.Error("alice's expired entry is still cached")
+	}
+	if _, ok := c.entries["bob"]; !ok {
+		t.Error("bob's fresh entry isn't cached")
+	}
+}
+
+func TestScoredKeyQualifyRule(t *testing.T) {
+	def, ok := scoredKey("alice", ScoringOptions{Method: "minmax"})
+	if !ok {
+		t.Fatal("the default qualifying rule isn't cacheable")
+	}
+
+	custom := func(m *Mod) bool { return m.Pips == 6 }
+	if _, ok := scoredKey("alice", ScoringOptions{Method: "minmax", Qualifies: custom}); ok {
+		t.Error("an unnamed Qualifies is cacheable")
+	}
+
+	named, ok := scoredKey("alice", ScoringOptions{Method: "minmax", Qualifies: custom, QualifyRule: "six-dot"})
+	if !ok || named == def {
+		t.Errorf("named rule key %q (cacheable %v), want one other than the default's %q", named, ok, def)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// modCodes are the game's numeric set and slot codes to add or rename, as
+// read from -mod-codes, e.g. {"sets": {"9": "newset"}, "setPieces":
+// {"newset": 2}}. SetPieces gives how many mods of a set earn its bonus; a
+// new set without one is display-only, earning no bonus in loadouts and
+// not accepted by sets=.
+type modCodes struct {
+	Sets      map[string]string `json:"sets"`
+	Slots     map[string]string `json:"slots"`
+	SetPieces map[string]int    `json:"setPieces"`
+}
+
+// loadModCodes merges the codes in path over modSetMap and modSlotMap, so
+// a new set or slot can be read without a rebuild.
+func loadModCodes(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var codes modCodes
+	if err := json.Unmarshal(data, &codes); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+
+	for kind, m := range map[string]map[string]string{"set": codes.Sets, "slot": codes.Slots} {
+		for code, name := range m {
+			if _, err := strconv.Atoi(code); err != nil {
+				return fmt.Errorf("%s: %s code %q is not a number", path, kind, code)
+			}
+			if name == "" {
+				return fmt.Errorf("%s: %s code %s has no name", path, kind, code)
+			}
+		}
+	}
+
+	for set, pieces := range codes.SetPieces {
+		if ggCode(modSetMap, set) == 0 && ggCode(codes.Sets, set) == 0 {
+			return fmt.Errorf("%s: piece count for unknown set %s", path, set)
+		}
+		if pieces < 1 || pieces > loadoutSlots {
+			return fmt.Errorf("%s: set %s needs between 1 and %d pieces, not %d", path, set, loadoutSlots, pieces)
+		}
+	}
+
+	for code, name := range codes.Sets {
+		modSetMap[code] = name
+	}
+	for code, name := range codes.Slots {
+		modSlotMap[code] = name
+	}
+
+	for set, pieces := range codes.SetPieces {
+		setPieces[set] = pieces
+	}
+
+	return nil
+}
+
+var warnedCodes sync.Map
+
+// lookupCode names a set or slot code, warning the first time a code shows
+// up that -mod-codes could teach it about.
+func lookupCode(codes map[string]string, kind, code string) string {
+	name, ok := codes[code]
+	if !ok {
+		if _, warned := warnedCodes.LoadOrStore(kind+":"+code, true); !warned {
+			slog.Warn("Unknown mod code, add it with -mod-codes", "kind", kind, "code", code)
+		}
+	}
+	return name
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeModCodes(t *testing.T, codes string) string {
+	t.Helper()
+
+	savedSets, savedSlots, savedPieces := maps.Clone(modSetMap), maps.Clone(modSlotMap), maps.Clone(setPieces)
+	t.Cleanup(func() {
+		modSetMap, modSlotMap, setPieces = savedSets, savedSlots, savedPieces
+	})
+
+	path := filepath.Join(t.TempDir(), "codes.json")
+	if err := os.WriteFile(path, []byte(codes), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadModCodesSetPieces(t *testing.T) {
+	path := writeModCodes(t, `{"sets": {"9": "newset"}, "setPieces": {"newset": 2}}`)
+
+	if err := loadModCodes(path); err != nil {
+		t.Fatal(err)
+	}
+
+	reqs, err := parseSetRequirements("newset,speed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reqs) != 2 || reqs[1] != (setRequirement{"newset", 2}) {
+		t.Errorf("got %v, want speed then newset with 2 pieces", reqs)
+	}
+}
+
+func TestLoadModCodesDisplayOnlySet(t *testing.T) {
+	path := writeModCodes(t, `{"sets": {"9": "newset"}}`)
+
+	if err := loadModCodes(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if modSetMap["9"] != "newset" {
+		t.Errorf("got set %q for code 9, want newset", modSetMap["9"])
+	}
+	if _, err := parseSetRequirements("newset"); err == nil {
+		t.Error("sets=newset was accepted without a piece count")
+	}
+}
+
+func TestLoadModCodesBadSetPieces(t *testing.T) {
+	for _, codes := range []string{
+		`{"setPieces": {"nosuchset": 2}}`,
+		`{"sets": {"9": "newset"}, "setPieces": {"newset": 0}}`,
+		`{"sets": {"9": "newset"}, "setPieces": {"newset": 7}}`,
+	} {
+		if err := loadModCodes(writeModCodes(t, codes)); err == nil {
+			t.Errorf("%s: loaded without error", codes)
+		}
+	}
+}
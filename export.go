@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+)
+
+// The swgoh.gg export writes mods in the schema of the swgoh.gg player API
+// (/api/players/ALLYCODE/mods/), the one -source ggjson reads, so it can be
+// fed to tools that take that API's mods:
+//
+//	{"mods": [...], "count": N}
+//
+// with each mod as
+//
+//	id              Uid
+//	level           Level
+//	rarity          Pips
+//	set             the set's game code, e.g. 4 for speed (0 if unknown)
+//	slot            the slot's game code, e.g. 2 for arrow (0 if unknown)
+//	primary_stat    PrimaryStat
+//	secondary_stats SecondaryStats, in the order shown in game
+//	character       CharacterName, empty for an unequipped mod
+//
+// and each stat as its name, the game's unit stat id (see ggStatNames) and
+// its display value, e.g. {"name": "Speed", "stat_id": 5, "display_value":
+// "12"} or {"name": "Offense %", "stat_id": 48, "display_value": "1.5%"}.
+// Scores, grades and other values this tool works out aren't part of it.
+
+// ggStatID is the unit stat id for statType, the inverse of ggStatNames
+// and ggPercentStats.
+func ggStatID(statType string) (int, bool) {
+	name, percent := strings.CutSuffix(statType, " %")
+	for id, n := range ggStatNames {
+		if n == name && ggPercentStats[id] == percent {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// ggCode is the game code that codes names name by, or 0 if none does.
+func ggCode(codes map[string]string, name string) int {
+	for code, n := range codes {
+		if n == name && name != "" {
+			i, _ := strconv.Atoi(code)
+			return i
+		}
+	}
+	return 0
+}
+
+func toGGStat(s Stat) (ggStat, error) {
+	id, ok := ggStatID(s.Type)
+	if !ok {
+		return ggStat{}, fmt.Errorf("no stat id for %s", s.Type)
+	}
+
+	value := strconv.FormatFloat(s.Value, 'f', -1, 64)
+	if strings.HasSuffix(s.Type, "%") {
+		value += "%"
+	}
+
+	return ggStat{s.Type, id, value}, nil
+}
+
+func toGGMod(m *Mod) (ggMod, error) {
+	primaryStat, err := toGGStat(m.PrimaryStat.Stat)
+	if err != nil {
+		return ggMod{}, fmt.Errorf("mod %s: bad primary stat: %v", m.Uid, err)
+	}
+
+	secondaryStats := make([]ggStat, 0, len(m.SecondaryStats))
+	for _, s := range m.SecondaryStats {
+		stat, err := toGGStat(s.Stat)
+		if err != nil {
+			return ggMod{}, fmt.Errorf("mod %s: bad secondary stat: %v", m.Uid, err)
+		}
+		secondaryStats = append(secondaryStats, stat)
+	}
+
+	character := ""
+	if m.Equipped {
+		character = m.CharacterName
+	}
+
+	return ggMod{
+		ID:             m.Uid,
+		Level:          m.Level,
+		Rarity:         m.Pips,
+		Set:            ggCode(modSetMap, m.Set),
+		Slot:           ggCode(modSlotMap, m.Slot),
+		PrimaryStat:    primaryStat,
+		SecondaryStats: secondaryStats,
+		Character:      character,
+	}, nil
+}
+
+// exportGGMods converts mods to a swgoh.gg player API response, skipping
+// any with a stat the API has no id for.
+func exportGGMods(logger *slog.Logger, mods []*Mod) ggModsResponse {
+	res := ggModsResponse{Mods: make([]ggMod, 0, len(mods))}
+
+	for _, m := range mods {
+		gm, err := toGGMod(m)
+
+		if err != nil {
+			logger.Warn("Skipping mod in export", "err", err)
+			continue
+		}
+
+		res.Mods = append(res.Mods, gm)
+	}
+
+	res.Count = len(res.Mods)
+	return res
+}
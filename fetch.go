@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var (
+	errRateLimited  = errors.New("rate limited by swgoh.gg")
+	errUserNotFound = errors.New("user not found on swgoh.gg")
+)
+
+const maxRetryAfter = 30 * time.Second
+
+// upstreamClient makes every request to swgoh.gg. main replaces it with one
+// tuned by newUpstreamClient.
+var upstreamClient = http.DefaultClient
+
+// newUpstreamClient builds a client whose idle connection pool is sized by
+// -max-idle-conns, -max-idle-conns-per-host and -idle-conn-timeout. Nearly
+// every request goes to the one host, so the per-host limit is the one that
+// matters: Go's default of 2 makes most parallel page fetches dial anew.
+func newUpstreamClient() *http.Client {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.MaxIdleConns = *maxIdleConns
+	t.MaxIdleConnsPerHost = *maxIdleConnsPerHost
+	t.IdleConnTimeout = *idleConnTimeout
+	return &http.Client{Transport: t}
+}
+
+// retryAfter reads a Retry-After header given either in seconds or as an
+// HTTP date, falling back to a growing backoff when it is missing.
+func retryAfter(resp *http.Response, attempt int) time.Duration {
+	wait := time.Duration(attempt) * 2 * time.Second
+
+	if v := resp.Header.Get("Retry-After"); v != "" {
+		if secs, err := strconv.Atoi(v); err == nil {
+			wait = time.Duration(secs) * time.Second
+		} else if t, err := http.ParseTime(v); err == nil {
+			wait = time.Until(t)
+		}
+	}
+
+	return min(max(wait, 0), maxRetryAfter)
+}
+
+// httpGet fetches url, backing off and retrying when swgoh.gg answers 429.
+// Any other status than 200 is returned as an error so callers never parse
+// an error or maintenance page as if it were data.
+func httpGet(ctx context.Context, url string) (*http.Response, error) {
+	logger := loggerFrom(ctx)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 1; ; attempt++ {
+		resp, err := upstreamClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode == http.StatusOK {
+			return resp, nil
+		}
+
+		resp.Body.Close()
+
+		switch resp.StatusCode {
+		case http.StatusTooManyRequests:
+		case http.StatusNotFound:
+			return nil, fmt.Errorf("%w: %s", errUserNotFound, url)
+		default:
+			return nil, fmt.Errorf("swgoh.gg returned %s for %s", resp.Status, url)
+		}
+
+		if attempt > *rateLimitRetries {
+			return nil, fmt.Errorf("%w: %s", errRateLimited, url)
+		}
+
+		wait := retryAfter(resp, attempt)
+		logger.Warn("Rate limited, backing off", "url", url, "attempt", attempt, "wait", wait)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+)
+
+var primaryStatTypes = map[string]bool{
+	"Speed":                true,
+	"Offense %":            true,
+	"Defense %":            true,
+	"Health %":             true,
+	"Protection %":         true,
+	"Critical Chance %":    true,
+	"Critical Damage %":    true,
+	"Critical Avoidance %": true,
+	"Accuracy %":           true,
+	"Potency %":            true,
+	"Tenacity %":           true,
+}
+
+type modFilter struct {
+	Primary    string
+	Slot       string
+	MinScore   int
+	SpeedArrow bool
+	Potential  bool
+	Unassigned bool
+	// Stat keeps mods with this secondary, valued at least StatMin.
+	Stat      string
+	StatMin   float64
+	SortBy    string
+	Ascending bool
+}
+
+var sortOrders = map[string]bool{"asc": true, "desc": false}
+
+// parseOrder reads ?order=asc|desc, falling back to def when it is absent.
+// It reports whether the list should be ascending.
+func parseOrder(q url.Values, def string) (bool, error) {
+	order := q.Get("order")
+	if order == "" {
+		order = def
+	}
+
+	ascending, ok := sortOrders[order]
+	if !ok {
+		return false, fmt.Errorf("bad order %q, want asc or desc", order)
+	}
+	return ascending, nil
+}
+
+func parseModFilter(q url.Values) (modFilter, error) {
+	var f modFilter
+
+	if primary := q.Get("primary"); primary != "" {
+		if !primaryStatTypes[primary] {
+			return f, fmt.Errorf("unknown primary stat %q", primary)
+		}
+		f.Primary = primary
+	}
+
+	if slot := q.Get("slot"); slot != "" {
+		if slotIndex(slot) > len(modSlotMap) {
+			return f, fmt.Errorf("unknown slot %q", slot)
+		}
+		f.Slot = slot
+	}
+
+	if minScore := q.Get("minscore"); minScore != "" {
+		v, err := strconv.ParseFloat(minScore, 64)
+		if err != nil {
+			return f, fmt.Errorf("bad minscore %q", minScore)
+		}
+		// minscore is given on the -score-scale shown to the user.
+		f.MinScore = int(math.Ceil(unpresentValue(v)))
+	}
+
+	if speedArrow := q.Get("speedarrow"); speedArrow != "" {
+		v, err := strconv.ParseBool(speedArrow)
+		if err != nil {
+			return f, fmt.Errorf("bad speedarrow %q", speedArrow)
+		}
+		f.SpeedArrow = v
+	}
+
+	if potential := q.Get("potential"); potential != "" {
+		v, err := strconv.ParseBool(potential)
+		if err != nil {
+			return f, fmt.Errorf("bad potential %q", potential)
+		}
+		f.Potential = v
+	}
+
+	if unassigned := q.Get("unassigned"); unassigned != "" {
+		v, err := strconv.ParseBool(unassigned)
+		if err != nil {
+			return f, fmt.Errorf("bad unassigned %q", unassigned)
+		}
+		f.Unassigned = v
+	}
+
+	// "Speed", "Offense%" and "Offense %" all name the same stats as the
+	// mods page does.
+	if stat := q.Get("stat"); stat != "" {
+		f.Stat = normalizeStatType(stat)
+	}
+
+	if statMin := q.Get("statmin"); statMin != "" {
+		if f.Stat == "" {
+			return f, fmt.Errorf("statmin needs a stat")
+		}
+		v, err := strconv.ParseFloat(statMin, 64)
+		if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+			return f, fmt.Errorf("bad statmin %q", statMin)
+		}
+		f.StatMin = v
+	}
+
+	switch sortBy := q.Get("sort"); sortBy {
+	case "", "score":
+	case "survivability":
+		if !*survivability {
+			return f, fmt.Errorf("sort=survivability needs the server started with -survivability")
+		}
+		f.SortBy = sortBy
+	default:
+		return f, fmt.Errorf("bad sort %q, want score or survivability", sortBy)
+	}
+
+	ascending, err := parseOrder(q, *defaultOrder)
+	if err != nil {
+		return f, err
+	}
+	f.Ascending = ascending
+
+	return f, nil
+}
+
+func (f modFilter) match(m *Mod) bool {
+	if f.Primary != "" && m.PrimaryStat.Type != f.Primary {
+		return false
+	}
+	if f.Slot != "" && m.Slot != f.Slot {
+		return false
+	}
+	if m.TotalScore < f.MinScore {
+		return false
+	}
+	if f.SpeedArrow && !m.IsSpeedArrow {
+		return false
+	}
+	if f.Potential && !highPotential(m) {
+		return false
+	}
+	if f.Stat != "" {
+		if s := m.secondary(f.Stat); s == nil || s.Value < f.StatMin {
+			return false
+		}
+	}
+	if f.Unassigned && m.CharacterName != "" {
+		return false
+	}
+	return true
+}
+
+// highPotential reports whether a mod still to be levelled already has
+// secondaries averaging at least -potential-score, making it worth the
+// credits to finish.
+func highPotential(m *Mod) bool {
+	if m.Level >= maxModLevel || len(m.SecondaryStats) == 0 {
+		return false
+	}
+
+	total := 0
+	for _, stat := range m.SecondaryStats {
+		total += stat.Score
+	}
+
+	return float64(total)/float64(len(m.SecondaryStats)) >= *potentialScore
+}
+
+func (f modFilter) apply(mods []*Mod) []*Mod {
+	var filtered []*Mod
+	for _, m := range mods {
+		if f.match(m) {
+			filtered = append(filtered, m)
+		}
+	}
+
+	// Mods arrive best first, or by slot when scoring is off.
+	if f.SortBy == "survivability" {
+		sort.SliceStable(filtered, func(i, j int) bool {
+			return filtered[i].Survivability > filtered[j].Survivability
+		})
+	}
+
+	if f.Ascending {
+		slices.Reverse(filtered)
+	}
+
+	return filtered
+}
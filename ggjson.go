@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+type ggStat struct {
+	Name         string `json:"name"`
+	StatID       int    `json:"stat_id"`
+	DisplayValue string `json:"display_value"`
+}
+
+type ggMod struct {
+	ID             string   `json:"id"`
+	Level          int      `json:"level"`
+	Rarity         int      `json:"rarity"`
+	Set            int      `json:"set"`
+	Slot           int      `json:"slot"`
+	PrimaryStat    ggStat   `json:"primary_stat"`
+	SecondaryStats []ggStat `json:"secondary_stats"`
+	Character      string   `json:"character"`
+}
+
+type ggModsResponse struct {
+	Mods  []ggMod `json:"mods"`
+	Count int     `json:"count"`
+}
+
+// ggStatNames maps the game's unit stat ids to the labels used on the
+// swgoh.gg mods pages, so both sources score against the same stat types.
+var ggStatNames = map[int]string{
+	1:  "Health",
+	5:  "Speed",
+	16: "Critical Damage",
+	17: "Potency",
+	18: "Tenacity",
+	28: "Protection",
+	41: "Offense",
+	42: "Defense",
+	48: "Offense",
+	49: "Defense",
+	52: "Accuracy",
+	53: "Critical Chance",
+	54: "Critical Avoidance",
+	55: "Health",
+	56: "Protection",
+}
+
+var ggPercentStats = map[int]bool{
+	16: true,
+	17: true,
+	18: true,
+	48: true,
+	49: true,
+	52: true,
+	53: true,
+	54: true,
+	55: true,
+	56: true,
+}
+
+func convertGGStat(s ggStat) (Stat, error) {
+	name, ok := ggStatNames[s.StatID]
+	if !ok {
+		return Stat{}, fmt.Errorf("unknown stat id %d (%s)", s.StatID, s.Name)
+	}
+
+	stat, err := parseStat(name, s.DisplayValue)
+	if err != nil {
+		return Stat{}, err
+	}
+
+	if ggPercentStats[s.StatID] && !strings.HasSuffix(stat.Type, "%") {
+		stat.Type = fmt.Sprintf("%s %%", name)
+	}
+
+	return stat, nil
+}
+
+func convertGGMod(gm ggMod) (*Mod, error) {
+	primaryStat, err := convertGGStat(gm.PrimaryStat)
+	if err != nil {
+		return nil, fmt.Errorf("mod %s: bad primary stat: %v", gm.ID, err)
+	}
+
+	var secondaryStats []*SecondaryStat
+	for _, gs := range gm.SecondaryStats {
+		stat, err := convertGGStat(gs)
+		if err != nil {
+			return nil, fmt.Errorf("mod %s: bad secondary stat: %v", gm.ID, err)
+		}
+		secondaryStats = append(secondaryStats, &SecondaryStat{stat, 0})
+	}
+
+	slot := lookupCode(modSlotMap, "slot", strconv.Itoa(gm.Slot))
+	set := lookupCode(modSetMap, "set", strconv.Itoa(gm.Set))
+
+	if *strict && (slot == "" || set == "") {
+		return nil, fmt.Errorf("mod %s: unknown set %d or slot %d", gm.ID, gm.Set, gm.Slot)
+	}
+
+	// UpgradeCost stays 0: the player API doesn't report upgrade costs.
+	mod := Mod{
+		Uid:                 gm.ID,
+		Slot:                slot,
+		Set:                 set,
+		Level:               gm.Level,
+		Pips:                gm.Rarity,
+		CharacterName:       gm.Character,
+		Equipped:            gm.Character != "",
+		PrimaryStat:         PrimaryStat{primaryStat},
+		SecondaryStats:      secondaryStats,
+		RevealedSecondaries: len(secondaryStats),
+		IsSpeedArrow:        slot == "arrow" && primaryStat.Type == "Speed",
+		PrimaryMaxed:        primaryMaxed(gm.Rarity, primaryStat),
+	}
+
+	return &mod, nil
+}
+
+func fetchGGJSONMods(ctx context.Context, allyCode string, progress progressFunc) (*ScrapeResult, error) {
+	resp, err := httpGet(ctx, fmt.Sprintf("%s/api/players/%s/mods/", strings.TrimSuffix(*baseURL, "/"), allyCode))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch mods: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var data ggModsResponse
+
+	if err := json.NewDecoder(io.LimitReader(resp.Body, *maxPageBytes)).Decode(&data); err != nil {
+		return nil, fmt.Errorf("failed to decode mods: %v", err)
+	}
+
+	var mods []*Mod
+
+	for _, gm := range data.Mods {
+		mod, err := convertGGMod(gm)
+
+		if err != nil {
+			if *strict {
+				return nil, parseError{err}
+			}
+			loggerFrom(ctx).Warn("Skipping mod", "err", err)
+			continue
+		}
+
+		mods = append(mods, mod)
+	}
+
+	// As with the HTML pages, mods that all fail to convert mean the API
+	// has moved on, not that the account is empty.
+	if len(mods) == 0 && len(data.Mods) > 0 {
+		loggerFrom(ctx).Error("Mods fetched but none converted, check the stat and mod codes", "user", allyCode, "mods", len(data.Mods))
+		return nil, errNoModsParsed
+	}
+
+	progress.report("pages", 1, 1)
+
+	return &ScrapeResult{
+		Mods:      mods,
+		PageCount: 1,
+	}, nil
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFetchGGJSONModsNoneConverted(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"mods": [{"id": "a", "slot": 1, "set": 1, "rarity": 5, "level": 15, "primary_stat": {"stat_id": 999, "display_value": "5"}}], "count": 1}`))
+	}))
+	defer srv.Close()
+
+	saved := *baseURL
+	*baseURL = srv.URL
+	defer func() { *baseURL = saved }()
+
+	if _, err := fetchGGJSONMods(context.Background(), "123456789", nil); !errors.Is(err, errNoModsParsed) {
+		t.Errorf("got %v, want errNoModsParsed", err)
+	}
+}
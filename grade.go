@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxTotalScore is the best possible TotalScore: four secondaries each
+// scoring 100.
+const maxTotalScore = maxSecondaries * 100
+
+type gradeThreshold struct {
+	Grade string
+	Min   float64
+}
+
+var gradeThresholds []gradeThreshold
+
+// parseGradeThresholds reads grades and the lowest share of maxTotalScore,
+// as a percentage, that earns them, e.g. "A=75,B=60". Mods below every
+// threshold are graded F.
+func parseGradeThresholds(raw string) ([]gradeThreshold, error) {
+	var thresholds []gradeThreshold
+	for _, part := range strings.Split(raw, ",") {
+		grade, min, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok || grade == "" {
+			return nil, fmt.Errorf("bad grade threshold %q, want GRADE=PERCENT", part)
+		}
+
+		v, err := strconv.ParseFloat(min, 64)
+		if err != nil || v < 0 || v > 100 {
+			return nil, fmt.Errorf("bad grade threshold %q, percent must be 0-100", part)
+		}
+
+		thresholds = append(thresholds, gradeThreshold{grade, v})
+	}
+
+	sort.Slice(thresholds, func(i, j int) bool {
+		return thresholds[i].Min > thresholds[j].Min
+	})
+
+	return thresholds, nil
+}
+
+func grade(totalScore int) string {
+	percent := float64(totalScore) / maxTotalScore * 100
+	for _, t := range gradeThresholds {
+		if percent >= t.Min {
+			return t.Grade
+		}
+	}
+	return "F"
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const maxGuildUsers = 50
+
+// normalizeUser gives the form of a username that is scraped and cached.
+// swgoh.gg profile URLs are lowercase and don't care about case, so "MyName",
+// " myname " and "myname/" are all the same user; surrounding whitespace and
+// slashes are dropped and the rest lowercased. Ally codes pass through
+// unchanged apart from the trimming.
+func normalizeUser(user string) string {
+	return strings.ToLower(strings.Trim(user, " \t\r\n/"))
+}
+
+// userParam reads the u parameter in its normalized form.
+func userParam(q url.Values) string {
+	return normalizeUser(q.Get("u"))
+}
+
+func parseUsers(raw string) ([]string, error) {
+	var users []string
+	seen := make(map[string]bool)
+
+	for _, u := range strings.Split(raw, ",") {
+		u = normalizeUser(u)
+		if u == "" || seen[u] {
+			continue
+		}
+		seen[u] = true
+		users = append(users, u)
+	}
+
+	if len(users) == 0 {
+		return nil, fmt.Errorf("no users given")
+	}
+	if len(users) > maxGuildUsers {
+		return nil, fmt.Errorf("at most %d users can be compared at once", maxGuildUsers)
+	}
+
+	return users, nil
+}
+
+// getGuildMods scrapes each user and returns all their mods tagged with
+// their owner. When pooled, every mod is scored against the combined
+// population so a score means the same thing for everyone; otherwise each
+// user's mods keep the scores from their own collection.
+func getGuildMods(ctx context.Context, users []string, opts ScoringOptions, pooled bool) ([]*Mod, error) {
+	raws := make([]*ScrapeResult, len(users))
+	errs := make([]error, len(users))
+
+	var wg sync.WaitGroup
+	for i, user := range users {
+		wg.Add(1)
+		go func(i int, user string) {
+			defer wg.Done()
+			if pooled {
+				raws[i], _, errs[i] = scrapes.get(ctx, user, nil)
+			} else {
+				raws[i], errs[i] = getMods(ctx, user, opts)
+			}
+		}(i, user)
+	}
+	wg.Wait()
+
+	for i, user := range users {
+		if errs[i] != nil {
+			return nil, fmt.Errorf("%s: %w", user, errs[i])
+		}
+	}
+
+	return poolMods(users, raws, opts, pooled), nil
+}
+
+// ownerAliases maps each user to the pseudonym -anonymize-owners shows in
+// their place, numbered by their position in users so the same list always
+// gets the same names.
+func ownerAliases(users []string) map[string]string {
+	aliases := make(map[string]string, len(users))
+	for i, u := range users {
+		aliases[u] = fmt.Sprintf("Player-%d", i+1)
+	}
+	return aliases
+}
+
+// poolMods tags each user's mods with their owner, or their alias under
+// -anonymize-owners, and merges them into one list, best first. With pooled
+// set they are rescored against the combined population first. The results
+// are cloned, never modified.
+func poolMods(users []string, results []*ScrapeResult, opts ScoringOptions, pooled bool) []*Mod {
+	total := 0
+	for _, res := range results {
+		total += len(res.Mods)
+	}
+
+	mods := make([]*Mod, 0, total)
+
+	owners := users
+	if *anonymizeOwners {
+		aliases := ownerAliases(users)
+		owners = make([]string, len(users))
+		for i, u := range users {
+			owners[i] = aliases[u]
+		}
+	}
+
+	for i := range users {
+		for _, m := range cloneMods(results[i].Mods) {
+			m.Owner = owners[i]
+			mods = append(mods, m)
+		}
+	}
+
+	// The pooled bounds come from the combined mods, so which mods qualify
+	// is decided once for everyone rather than per user.
+	if pooled && !opts.Disabled {
+		bounds, _ := scoringPopulation(mods, opts.qualify)
+		scoreMods(mods, bounds, opts)
+	}
+
+	sort.SliceStable(mods, func(i, j int) bool {
+		if mods[i].TotalScore != mods[j].TotalScore {
+			return mods[i].TotalScore > mods[j].TotalScore
+		}
+		return mods[i].Uid < mods[j].Uid
+	})
+
+	rankMods(mods)
+
+	return mods
+}
+
+func groupByOwner(mods []*Mod, users []string) []*Mod {
+	aliases := ownerAliases(users)
+	order := make(map[string]int, len(users))
+	for i, u := range users {
+		if *anonymizeOwners {
+			u = aliases[u]
+		}
+		order[u] = i
+	}
+
+	grouped := append([]*Mod(nil), mods...)
+	sort.SliceStable(grouped, func(i, j int) bool {
+		return order[grouped[i].Owner] < order[grouped[j].Owner]
+	})
+
+	return grouped
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestPoolModsQualifiesCombined checks pooled bounds are worked out over
+// the combined mods, so a user with too few qualifying mods of their own
+// doesn't bring their unqualified ones into everyone's bounds.
+func TestPoolModsQualifiesCombined(t *testing.T) {
+	var veteran []*Mod
+	for i := range 20 {
+		veteran = append(veteran, &Mod{
+			Uid:            fmt.Sprintf("v-%d", i),
+			Pips:           5,
+			Level:          15,
+			SecondaryStats: []*SecondaryStat{{Stat{"Speed", float64(i + 1), 0}, 0}},
+		})
+	}
+
+	// Level 1 mods never qualify; alone they'd be scored against each other.
+	newcomer := []*Mod{
+		{Uid: "n-1", Pips: 5, Level: 1, SecondaryStats: []*SecondaryStat{{Stat{"Speed", 99, 0}, 0}}},
+		{Uid: "n-2", Pips: 5, Level: 1, SecondaryStats: []*SecondaryStat{{Stat{"Speed", 1, 0}, 0}}},
+	}
+
+	results := make([]*ScrapeResult, 2)
+	for i, mods := range [][]*Mod{veteran, newcomer} {
+		bounds, _ := scoringPopulation(mods, qualifies)
+		results[i] = &ScrapeResult{Mods: mods, SecondaryScoreMap: bounds}
+	}
+
+	opts, err := scoringOptions(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, m := range poolMods([]string{"veteran", "newcomer"}, results, opts, true) {
+		if m.Uid == "v-19" && m.SecondaryStats[0].Score != 100 {
+			t.Errorf("the best qualifying speed scored %d, want 100", m.SecondaryStats[0].Score)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// gvMod is a mod as the grandivory mods optimizer exports it, with the
+// slot and set by name, stats by display name and value (e.g. "Offense %"
+// and "1.5") and up to four secondaries, an empty type for one not yet
+// revealed. characterID is the game's base id (e.g. BOSSK), which stands
+// in for the character's name.
+type gvMod struct {
+	ID                string `json:"mod_uid"`
+	Slot              string `json:"slot"`
+	Set               string `json:"set"`
+	Level             int    `json:"level"`
+	Pips              int    `json:"pips"`
+	PrimaryBonusType  string `json:"primaryBonusType"`
+	PrimaryBonusValue string `json:"primaryBonusValue"`
+	SecondaryType1    string `json:"secondaryType_1"`
+	SecondaryValue1   string `json:"secondaryValue_1"`
+	SecondaryType2    string `json:"secondaryType_2"`
+	SecondaryValue2   string `json:"secondaryValue_2"`
+	SecondaryType3    string `json:"secondaryType_3"`
+	SecondaryValue3   string `json:"secondaryValue_3"`
+	SecondaryType4    string `json:"secondaryType_4"`
+	SecondaryValue4   string `json:"secondaryValue_4"`
+	CharacterID       string `json:"characterID"`
+}
+
+// gvSetNames maps the set names the grandivory optimizer may use, with
+// spaces dropped and lowercased, to this tool's.
+var gvSetNames = map[string]string{
+	"criticalchance": "critchance",
+	"criticaldamage": "critdamage",
+}
+
+// gvStatNames maps stat names the grandivory optimizer abbreviates to the
+// swgoh.gg labels this tool scores by.
+var gvStatNames = map[string]string{
+	"Crit Chance %":    "Critical Chance %",
+	"Crit Damage %":    "Critical Damage %",
+	"Crit Avoidance %": "Critical Avoidance %",
+}
+
+func convertGVSet(raw string) string {
+	set := strings.ToLower(strings.ReplaceAll(raw, " ", ""))
+	if name, ok := gvSetNames[set]; ok {
+		set = name
+	}
+	if ggCode(modSetMap, set) == 0 {
+		return ""
+	}
+	return set
+}
+
+func convertGVStat(rawType, rawValue string) (Stat, error) {
+	statType := normalizeStatType(rawType)
+	if name, ok := gvStatNames[statType]; ok {
+		statType = name
+	}
+
+	// The type says whether a stat is a percentage; the value may not.
+	name, percent := strings.CutSuffix(statType, " %")
+	value := strings.TrimSpace(rawValue)
+	if percent && !strings.HasSuffix(value, "%") {
+		value += "%"
+	}
+
+	stat, err := parseStat(name, value)
+	if err != nil {
+		return Stat{}, err
+	}
+
+	if _, ok := ggStatID(stat.Type); !ok {
+		return Stat{}, fmt.Errorf("unknown stat %s", rawType)
+	}
+
+	return stat, nil
+}
+
+func convertGVMod(gm gvMod) (*Mod, error) {
+	primaryStat, err := convertGVStat(gm.PrimaryBonusType, gm.PrimaryBonusValue)
+	if err != nil {
+		return nil, fmt.Errorf("mod %s: bad primary stat: %v", gm.ID, err)
+	}
+
+	var secondaryStats []*SecondaryStat
+	for _, s := range [][2]string{
+		{gm.SecondaryType1, gm.SecondaryValue1},
+		{gm.SecondaryType2, gm.SecondaryValue2},
+		{gm.SecondaryType3, gm.SecondaryValue3},
+		{gm.SecondaryType4, gm.SecondaryValue4},
+	} {
+		if s[0] == "" {
+			continue
+		}
+		stat, err := convertGVStat(s[0], s[1])
+		if err != nil {
+			return nil, fmt.Errorf("mod %s: bad secondary stat: %v", gm.ID, err)
+		}
+		secondaryStats = append(secondaryStats, &SecondaryStat{stat, 0})
+	}
+
+	slot := strings.ToLower(gm.Slot)
+	if ggCode(modSlotMap, slot) == 0 {
+		slot = ""
+	}
+	set := convertGVSet(gm.Set)
+
+	if *strict && (slot == "" || set == "") {
+		return nil, fmt.Errorf("mod %s: unknown set %q or slot %q", gm.ID, gm.Set, gm.Slot)
+	}
+
+	// UpgradeCost stays 0: the export doesn't carry upgrade costs.
+	mod := Mod{
+		Uid:                 gm.ID,
+		Slot:                slot,
+		Set:                 set,
+		Level:               gm.Level,
+		Pips:                gm.Pips,
+		CharacterName:       gm.CharacterID,
+		Equipped:            gm.CharacterID != "",
+		PrimaryStat:         PrimaryStat{primaryStat},
+		SecondaryStats:      secondaryStats,
+		RevealedSecondaries: len(secondaryStats),
+		IsSpeedArrow:        slot == "arrow" && primaryStat.Type == "Speed",
+		PrimaryMaxed:        primaryMaxed(gm.Pips, primaryStat),
+	}
+
+	return &mod, nil
+}
+
+// readGVMods reads a grandivory mods export, either a bare array of mods
+// or an object holding them under "mods".
+func readGVMods(logger *slog.Logger, data []byte) ([]*Mod, error) {
+	var gms []gvMod
+
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		if err := json.Unmarshal(data, &gms); err != nil {
+			return nil, err
+		}
+	} else {
+		var export struct {
+			Mods []gvMod `json:"mods"`
+		}
+		if err := json.Unmarshal(data, &export); err != nil {
+			return nil, err
+		}
+		gms = export.Mods
+	}
+
+	return convertInputMods(logger, len(gms), func(i int) (*Mod, error) { return convertGVMod(gms[i]) })
+}
+
+// readGGMods reads a swgoh.gg player API mods response, such as
+// /api/mods?format=swgohgg writes.
+func readGGMods(logger *slog.Logger, data []byte) ([]*Mod, error) {
+	var res ggModsResponse
+	if err := json.Unmarshal(data, &res); err != nil {
+		return nil, err
+	}
+
+	return convertInputMods(logger, len(res.Mods), func(i int) (*Mod, error) { return convertGGMod(res.Mods[i]) })
+}
+
+// convertInputMods converts the n mods read from an input file, skipping
+// any that don't convert unless -strict is set.
+func convertInputMods(logger *slog.Logger, n int, convert func(int) (*Mod, error)) ([]*Mod, error) {
+	var mods []*Mod
+
+	for i := range n {
+		mod, err := convert(i)
+
+		if err != nil {
+			if *strict {
+				return nil, err
+			}
+			logger.Warn("Skipping mod", "err", err)
+			continue
+		}
+
+		mods = append(mods, mod)
+	}
+
+	return mods, nil
+}
+
+var inputFormats = map[string]func(*slog.Logger, []byte) ([]*Mod, error){
+	"grandivory": readGVMods,
+	"swgohgg":    readGGMods,
+}
+
+// readInputMods reads the mods in the -input file, in -input-format.
+func readInputMods(logger *slog.Logger, path, format string) ([]*Mod, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	mods, err := inputFormats[format](logger, data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	return mods, nil
+}
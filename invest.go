@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+const maxModLevel = 15
+
+// upgradeLevels are the levels at which a mod reveals a new secondary, or
+// upgrades one of its four once they are all revealed.
+var upgradeLevels = []int{3, 6, 9, 12}
+
+// rollsPerSecondary is how many rolls a secondary has had by the time its
+// mod is fully levelled, so one more roll is worth about that fraction of a
+// typical secondary's score.
+const rollsPerSecondary = 5
+
+type Investment struct {
+	Mod          *Mod    `json:"mod"`
+	ExpectedGain float64 `json:"expectedGain"`
+	Cost         int     `json:"cost"`
+	CostUnit     string  `json:"costUnit"`
+	Efficiency   float64 `json:"efficiency"`
+}
+
+// investments ranks un-maxed mods by the score they are expected to gain
+// from levelling per unit of cost, best first. The gain is a rough guess:
+// each upgrade level left either reveals a secondary worth the collection's
+// average secondary score or adds one roll to an existing secondary. Cost is
+// the mod's upgrade cost in credits when known and its levels left otherwise.
+// Gain per credit and gain per level don't compare, so mods priced in
+// credits are ranked first, among themselves, and those priced in levels
+// after them.
+func investments(mods []*Mod, count int) []Investment {
+	var statCount, scoreSum int
+	for _, m := range mods {
+		for _, stat := range m.SecondaryStats {
+			scoreSum += stat.Score
+			statCount++
+		}
+	}
+	if statCount == 0 {
+		return nil
+	}
+	meanScore := float64(scoreSum) / float64(statCount)
+
+	var ranked []Investment
+	for _, m := range mods {
+		if m.Level >= maxModLevel {
+			continue
+		}
+
+		gain := 0.0
+		revealed := m.RevealedSecondaries
+		for _, l := range upgradeLevels {
+			if l <= m.Level {
+				continue
+			}
+			if revealed < maxSecondaries {
+				gain += meanScore
+				revealed++
+			} else {
+				gain += meanScore / rollsPerSecondary
+			}
+		}
+		if gain == 0 {
+			continue
+		}
+
+		inv := Investment{m, gain, m.UpgradeCost, "credits", 0}
+		if inv.Cost <= 0 {
+			inv.Cost, inv.CostUnit = maxModLevel-m.Level, "levels"
+		}
+		inv.Efficiency = gain / float64(inv.Cost)
+
+		ranked = append(ranked, inv)
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].CostUnit != ranked[j].CostUnit {
+			return ranked[i].CostUnit == "credits"
+		}
+		if ranked[i].Efficiency != ranked[j].Efficiency {
+			return ranked[i].Efficiency > ranked[j].Efficiency
+		}
+		return ranked[i].Mod.Uid < ranked[j].Mod.Uid
+	})
+
+	if len(ranked) > count {
+		ranked = ranked[:count]
+	}
+
+	return ranked
+}
+
+func (inv Investment) MarshalJSON() ([]byte, error) {
+	type investment Investment
+	return json.Marshal(struct {
+		investment
+		ExpectedGain float64 `json:"expectedGain"`
+		Efficiency   float64 `json:"efficiency"`
+	}{investment(inv), presentValue(inv.ExpectedGain), presentValue(inv.Efficiency)})
+}
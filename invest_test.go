@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestInvestmentsRankCostUnitsApart(t *testing.T) {
+	secondaries := func() []*SecondaryStat {
+		stats := make([]*SecondaryStat, maxSecondaries)
+		for i := range stats {
+			stats[i] = &SecondaryStat{Stat{Type: "Speed", Value: 5}, 50}
+		}
+		return stats
+	}
+
+	// Gain per level left dwarfs gain per credit, so ranked together the
+	// level-priced mod would always come first.
+	mods := []*Mod{
+		{Uid: "by-level", Level: 9, SecondaryStats: secondaries(), RevealedSecondaries: maxSecondaries},
+		{Uid: "by-credit", Level: 9, UpgradeCost: 50000, SecondaryStats: secondaries(), RevealedSecondaries: maxSecondaries},
+	}
+
+	ranked := investments(mods, 10)
+	if len(ranked) != 2 {
+		t.Fatalf("got %d investments, want 2", len(ranked))
+	}
+	if ranked[0].Mod.Uid != "by-credit" || ranked[0].CostUnit != "credits" {
+		t.Errorf("first is %s priced in %s, want by-credit in credits", ranked[0].Mod.Uid, ranked[0].CostUnit)
+	}
+	if ranked[1].Mod.Uid != "by-level" || ranked[1].CostUnit != "levels" || ranked[1].Cost != maxModLevel-9 {
+		t.Errorf("second is %s costing %d %s, want by-level costing %d levels", ranked[1].Mod.Uid, ranked[1].Cost, ranked[1].CostUnit, maxModLevel-9)
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var loadoutStrategies = map[string]bool{"any": true, "single-set": true}
+
+// availableMods are the mods that could go on character: ones it already
+// wears and unassigned ones. Without a character every mod is available.
+func availableMods(mods []*Mod, character string) []*Mod {
+	if character == "" {
+		return mods
+	}
+
+	var available []*Mod
+	for _, m := range mods {
+		if m.CharacterName == "" || m.CharacterName == character {
+			available = append(available, m)
+		}
+	}
+	return available
+}
+
+// bestPerSlot picks the mod p rates highest in each slot, in slot order,
+// with ties going to the lower Uid. Slots with no mod are left out.
+func bestPerSlot(mods []*Mod, p profile) []*Mod {
+	best := make(map[string]*Mod)
+	for _, m := range mods {
+		b, ok := best[m.Slot]
+		if !ok || p.score(m) > p.score(b) || p.score(m) == p.score(b) && m.Uid < b.Uid {
+			best[m.Slot] = m
+		}
+	}
+
+	loadout := make([]*Mod, 0, len(best))
+	for _, m := range best {
+		loadout = append(loadout, m)
+	}
+	sort.Slice(loadout, func(i, j int) bool {
+		return slotIndex(loadout[i].Slot) < slotIndex(loadout[j].Slot)
+	})
+	return loadout
+}
+
+func loadoutScore(loadout []*Mod, p profile) float64 {
+	total := 0.0
+	for _, m := range loadout {
+		total += p.score(m)
+	}
+	return total
+}
+
+// recommendMods suggests a mod for each slot. The any strategy takes the
+// best mod in every slot; single-set takes the set whose best mods score
+// highest together, so every mod shares one set. Mods of an unknown set,
+// or one with no known bonus, can't make a single-set loadout.
+func recommendMods(mods []*Mod, p profile, strategy string) ([]*Mod, error) {
+	switch strategy {
+	case "any":
+		return bestPerSlot(mods, p), nil
+	case "single-set":
+		bySet := make(map[string][]*Mod)
+		for _, m := range mods {
+			if setPieces[m.Set] == 0 {
+				continue
+			}
+			bySet[m.Set] = append(bySet[m.Set], m)
+		}
+
+		sets := make([]string, 0, len(bySet))
+		for set := range bySet {
+			sets = append(sets, set)
+		}
+		sort.Strings(sets)
+
+		var best []*Mod
+		bestScore := -1.0
+		for _, set := range sets {
+			loadout := bestPerSlot(bySet[set], p)
+			if score := loadoutScore(loadout, p); score > bestScore {
+				best, bestScore = loadout, score
+			}
+		}
+		return best, nil
+	}
+	return nil, fmt.Errorf("unknown strategy %q", strategy)
+}
+
+// setPieces is how many mods of a set it takes to earn its bonus once.
+var setPieces = map[string]int{
+	"health":     2,
+	"offense":    4,
+	"defense":    2,
+	"speed":      4,
+	"critchance": 2,
+	"critdamage": 4,
+	"potency":    2,
+	"tenacity":   2,
+}
+
+const loadoutSlots = 6
+
+type setRequirement struct {
+	Set    string
+	Pieces int
+}
+
+// parseSetRequirements reads the sets a loadout must complete, e.g.
+// "speed,health" for four speed and two health mods. Each set takes the
+// number of pieces its bonus needs, and together they must fit in six
+// slots.
+func parseSetRequirements(raw string) ([]setRequirement, error) {
+	if raw == "" {
+		return nil, fmt.Errorf("missing sets parameter")
+	}
+
+	var reqs []setRequirement
+	used := 0
+	for _, set := range strings.Split(raw, ",") {
+		set = strings.ToLower(strings.TrimSpace(set))
+		pieces, ok := setPieces[set]
+		if !ok {
+			return nil, fmt.Errorf("unknown set %q", set)
+		}
+
+		used += pieces
+		if used > loadoutSlots {
+			return nil, fmt.Errorf("sets %q need more than %d mods", raw, loadoutSlots)
+		}
+		reqs = append(reqs, setRequirement{set, pieces})
+	}
+
+	// Bigger sets have fewer ways to fit, so place them first.
+	sort.SliceStable(reqs, func(i, j int) bool {
+		return reqs[i].Pieces > reqs[j].Pieces
+	})
+
+	return reqs, nil
+}
+
+type Loadout struct {
+	Mods       []*Mod         `json:"mods"`
+	SetBonuses map[string]int `json:"setBonuses"`
+	Score      float64        `json:"score"`
+}
+
+// suggestLoadout greedily fills each required set with its best rated mods
+// in slots not yet taken, then fills the remaining slots with the best mod
+// of any set. It errors when a set doesn't have enough mods in free slots.
+func suggestLoadout(mods []*Mod, p profile, reqs []setRequirement) (Loadout, error) {
+	ranked := append([]*Mod(nil), mods...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if p.score(ranked[i]) != p.score(ranked[j]) {
+			return p.score(ranked[i]) > p.score(ranked[j])
+		}
+		return ranked[i].Uid < ranked[j].Uid
+	})
+
+	chosen := make(map[string]*Mod, loadoutSlots)
+
+	for _, req := range reqs {
+		placed := 0
+		for _, m := range ranked {
+			if placed == req.Pieces {
+				break
+			}
+			if _, taken := chosen[m.Slot]; taken || m.Set != req.Set {
+				continue
+			}
+			chosen[m.Slot] = m
+			placed++
+		}
+		if placed < req.Pieces {
+			return Loadout{}, fmt.Errorf("not enough %s mods in free slots: need %d, found %d", req.Set, req.Pieces, placed)
+		}
+	}
+
+	for _, m := range ranked {
+		if _, taken := chosen[m.Slot]; !taken {
+			chosen[m.Slot] = m
+		}
+	}
+
+	loadout := Loadout{SetBonuses: make(map[string]int)}
+	counts := make(map[string]int)
+	for _, m := range chosen {
+		loadout.Mods = append(loadout.Mods, m)
+		counts[m.Set]++
+	}
+	sort.Slice(loadout.Mods, func(i, j int) bool {
+		return slotIndex(loadout.Mods[i].Slot) < slotIndex(loadout.Mods[j].Slot)
+	})
+
+	for set, n := range counts {
+		// The free slots may go to a set with no known bonus.
+		if setPieces[set] == 0 {
+			continue
+		}
+		if bonuses := n / setPieces[set]; bonuses > 0 {
+			loadout.SetBonuses[set] = bonuses
+		}
+	}
+	loadout.Score = loadoutScore(loadout.Mods, p)
+
+	return loadout, nil
+}
+
+func (l Loadout) MarshalJSON() ([]byte, error) {
+	type loadout Loadout
+	return json.Marshal(struct {
+		loadout
+		Score float64 `json:"score"`
+	}{loadout(l), presentValue(l.Score)})
+}
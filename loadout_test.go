@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func loadoutMods(sets ...string) []*Mod {
+	slots := []string{"square", "arrow", "diamond", "triangle", "circle", "cross"}
+	mods := make([]*Mod, len(sets))
+	for i, set := range sets {
+		mods[i] = &Mod{Uid: fmt.Sprintf("m%d", i), Slot: slots[i%len(slots)], Set: set, TotalScore: 100 - i}
+	}
+	return mods
+}
+
+func TestSuggestLoadoutUnknownSet(t *testing.T) {
+	reqs, err := parseSetRequirements("speed")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, set := range []string{"", "newset"} {
+		mods := loadoutMods("speed", "speed", "speed", "speed", "speed", set)
+
+		loadout, err := suggestLoadout(mods, nil, reqs)
+		if err != nil {
+			t.Fatalf("set %q: %v", set, err)
+		}
+		if len(loadout.Mods) != loadoutSlots {
+			t.Errorf("set %q: got %d mods, want %d", set, len(loadout.Mods), loadoutSlots)
+		}
+		if got := loadout.SetBonuses["speed"]; got != 1 {
+			t.Errorf("set %q: got %d speed bonuses, want 1", set, got)
+		}
+		if _, ok := loadout.SetBonuses[set]; ok {
+			t.Errorf("set %q: got a bonus for it", set)
+		}
+	}
+}
+
+func TestRecommendSingleSetSkipsUnknownSets(t *testing.T) {
+	// The unknown-set mods come first, so they score highest together.
+	mods := loadoutMods("", "", "", "", "", "", "health", "health")
+
+	recommended, err := recommendMods(mods, nil, "single-set")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(recommended) != 2 {
+		t.Fatalf("got %d mods, want the 2 health mods", len(recommended))
+	}
+	for _, m := range recommended {
+		if m.Set != "health" {
+			t.Errorf("got %s of set %q, want health", m.Uid, m.Set)
+		}
+	}
+}
+
+func TestScoresFollowScoreScale(t *testing.T) {
+	saved := *scoreScale
+	*scoreScale = 10
+	defer func() { *scoreScale = saved }()
+
+	data, err := json.Marshal(Loadout{Score: 250})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var loadout struct{ Score float64 }
+	if err := json.Unmarshal(data, &loadout); err != nil {
+		t.Fatal(err)
+	}
+	if loadout.Score != 25 {
+		t.Errorf("loadout score 250 shows as %v out of 10s, want 25", loadout.Score)
+	}
+
+	if got := presentScore(250); got != 25 {
+		t.Errorf("presentScore(250) = %v, want 25", got)
+	}
+	if got := unpresentValue(presentValue(37)); got != 37 {
+		t.Errorf("unpresentValue(presentValue(37)) = %v, want 37", got)
+	}
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+)
+
+type loggerKey struct{}
+
+// withLogger returns ctx carrying logger, so a scrape and every page
+// fetch under it log with the request's attributes.
+func withLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// loggerFrom returns the logger ctx carries, or the default logger when it
+// carries none.
+func loggerFrom(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
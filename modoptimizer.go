@@ -1,42 +1,84 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	_ "embed"
+	"encoding/hex"
+	"errors"
 	"flag"
 	"fmt"
 	"html/template"
+	"io"
 	"log"
+	"log/slog"
 	"math"
 	"net/http"
+	"os"
+	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
-	"sort"
+	"sync/atomic"
+	"time"
 
 	"github.com/PuerkitoBio/goquery"
 )
 
 type Mod struct {
-	Uid            string           `json:"uid"`
-	Slot           string           `json:"slot"`
-	Set            string           `json:"set"`
-	Level          int              `json:"level"`
-	Pips           int              `json:"pips"`
-	TotalScore     int              `json:"totalScore"`
-	CharacterName  string           `json:"characterName"`
-	PrimaryStat    PrimaryStat      `json:"primaryStat"`
-	SecondaryStats []*SecondaryStat `json:"secondaryStats"`
+	Uid                 string           `json:"uid"`
+	Slot                string           `json:"slot"`
+	Set                 string           `json:"set"`
+	Level               int              `json:"level"`
+	UpgradeCost         int              `json:"upgradeCost,omitempty"`
+	Pips                int              `json:"pips"`
+	TotalScore          int              `json:"totalScore"`
+	Grade               string           `json:"grade,omitempty"`
+	Survivability       int              `json:"survivability,omitempty"`
+	CharacterName       string           `json:"characterName"`
+	Equipped            bool             `json:"equipped"`
+	Locked              bool             `json:"locked"`
+	PrimaryStat         PrimaryStat      `json:"primaryStat"`
+	SecondaryStats      []*SecondaryStat `json:"secondaryStats"`
+	RevealedSecondaries int              `json:"revealedSecondaries"`
+	IsSpeedArrow        bool             `json:"isSpeedArrow"`
+	PrimaryMaxed        bool             `json:"primaryMaxed"`
+	Rank                int              `json:"rank"`
+	Owner               string           `json:"owner,omitempty"`
+}
+
+func (m *Mod) secondary(statType string) *SecondaryStat {
+	for _, s := range m.SecondaryStats {
+		if s.Type == statType {
+			return s
+		}
+	}
+	return nil
+}
+
+func (m *Mod) secondaryValue(statType string) float64 {
+	if s := m.secondary(statType); s != nil {
+		return s.Value
+	}
+	return 0
 }
 
 type SecondaryScore struct {
-	Type string
-	Min  float64
-	Max  float64
+	Type   string
+	Min    float64
+	Max    float64
+	Values []float64
 }
 
 type Stat struct {
 	Type  string  `json:"type"`
 	Value float64 `json:"value"`
+	// Contextual is the value as shown for the equipped character, when
+	// swgoh.gg shows one. It is kept for display only: Value, the mod's own
+	// value, is what gets scored.
+	Contextual float64 `json:"contextual,omitempty"`
 }
 
 type PrimaryStat struct {
@@ -70,10 +112,91 @@ var (
 	}
 )
 
+func slotIndex(slot string) int {
+	for code, name := range modSlotMap {
+		if name == slot {
+			i, _ := strconv.Atoi(code)
+			return i
+		}
+	}
+	return len(modSlotMap) + 1
+}
+
+var modImageRegexp = regexp.MustCompile("statmodmystery_([0-9]+)_([0-9]+).png")
+
+var pageCountRegexp = regexp.MustCompile("Page [0-9]+ of ([0-9]+)")
+
 var (
-	httpPort = flag.Int("port", 8081, "HTTP port to listen on")
+	httpPort       = flag.Int("port", 8081, "HTTP port to listen on")
+	devMode        = flag.Bool("dev", false, "Re-read the page template on every request")
+	templatePath   = flag.String("template", "static/templates/index.html", "Page template to render mods with; the other .html files beside it are loaded as its partials")
+	groupStats     = flag.Bool("group-stats", false, "List flat and percent secondaries of the same stat next to each other on the page")
+	survivability  = flag.Bool("survivability", false, "Score health and protection secondaries together as a survivability value, sortable with ?sort=survivability")
+	scoreMethod    = flag.String("score-method", "minmax", "Secondary stat scoring method (minmax|percentile|absolute)")
+	scoreWorkers   = flag.Int("score-workers", 0, "Goroutines used to score a collection (0 uses GOMAXPROCS)")
+	noScore        = flag.Bool("no-score", false, "Skip scoring and list mods by slot and character")
+	defaultOrder   = flag.String("default-order", "desc", "Order mod lists by score, asc or desc, unless a request asks otherwise with ?order= (/sell is always worst first by default)")
+	minLevel       = flag.Int("min-level", 12, "Lowest mod level included when working out scoring bounds")
+	minPips        = flag.Int("min-pips", 4, "Fewest pips a mod needs to be included when working out scoring bounds")
+	minSecondaries = flag.Int("min-secondaries", 0, "Fewest revealed secondaries a mod needs to be included when working out scoring bounds")
+	strict         = flag.Bool("strict", false, "Fail a scrape on any parse anomaly (a mod that fails to parse, an unknown set or slot, a nameless character, an unreadable cost) instead of skipping or blanking it")
+	minQualifying  = flag.Int("min-qualifying", 10, "If fewer mods than this pass -min-level, -min-pips and -min-secondaries, all mods are used for scoring bounds")
+	leaveOneOut    = flag.Bool("leave-one-out", false, "Score each secondary against the population without that value, so the top mod of a stat doesn't set its own max (minmax and percentile only)")
+	sixDotBounds   = flag.Bool("six-dot-bounds", false, "Score 6-dot mods against bounds from 6-dot mods only, and the rest against bounds without them")
+	sixDotMin      = flag.Int("six-dot-min", 10, "Fewest qualifying mods each of the 6-dot and other buckets needs under -six-dot-bounds, else combined bounds are used")
+	baselinePath   = flag.String("baseline", "", "JSON file of community min/max bounds per stat type to score against instead of the user's own mods")
+	modCodesPath   = flag.String("mod-codes", "", "JSON file of set and slot codes to add to or override the built-in ones, e.g. {\"sets\": {\"9\": \"newset\"}, \"setPieces\": {\"newset\": 2}}; a new set without setPieces is display-only")
+	potentialScore = flag.Float64("potential-score", 70, "Average secondary score, out of 100, at which an unlevelled mod counts as worth levelling for ?potential=true")
+	grades         = flag.String("grades", "A=75,B=60,C=45,D=30", "Letter grades and the lowest percentage of the best possible total score that earns each; lower scores are graded F")
+	scoreScale     = flag.Float64("score-scale", 100, "Top per-secondary score shown (100, 10 or 1); totals are scaled to match")
+	scoreRounding  = flag.String("score-rounding", "round", "How a secondary score becomes a whole number: round (half away from zero), floor or ceil")
+	aggregateMode  = flag.String("aggregate", "sum", "How secondary scores make a mod total: sum (more revealed secondaries score higher) or avg (the mean scaled to four secondaries, fair to mods still levelling)")
+	speedWeight    = flag.Float64("speed-weight", 1, "Multiplier on the Speed secondary when ranking by profile (best, loadout); a profile that weights Speed itself keeps its own weight")
+
+	baseURL         = flag.String("base-url", "https://swgoh.gg", "Base URL of the swgoh.gg site (or a mirror) to fetch mods from")
+	pagePath        = flag.String("page-path", "/u/%s/mods/?page=%d", "Path of a mods page under -base-url, with %s for the username and %d for the page number")
+	modSource       = flag.String("source", "html", "Where to read mods from: html scrapes the swgoh.gg mods pages by username, ggjson reads the swgoh.gg player API by ally code")
+	validateUser    = flag.String("validate", "", "Check that the first mods page of this user still parses, print a report and exit (non-zero if parsing looks broken)")
+	selftest        = flag.Bool("selftest", false, "Parse and score the bundled fixture page, print whether it matches the expected mods and exit (non-zero if not); assumes the default scoring flags")
+	inputPath       = flag.String("input", "", "Score the mods in this file instead of scraping, print them to stdout as newline-delimited JSON and exit")
+	inputFormat     = flag.String("input-format", "grandivory", "Format of the -input file: grandivory (a grandivory mods optimizer export) or swgohgg (a swgoh.gg player API mods response, as /api/mods?format=swgohgg writes)")
+	ndjsonUser      = flag.String("ndjson", "", "Print this user's scored mods to stdout as newline-delimited JSON and exit")
+	usersFile       = flag.String("users-file", "", "Scrape and score every user listed in this file, one per line, writing USER.ndjson files to -out-dir, then exit")
+	outDir          = flag.String("out-dir", ".", "Directory -users-file writes its per-user files to")
+	crawlDelay      = flag.Duration("crawl-delay", time.Second, "How long -users-file waits between starting one user's scrape and the next (0 for no wait)")
+	guildTop        = flag.Int("guild-top", 0, "With -users-file, also write the best N mods across all users, scored against their combined mods, to guild-top.csv")
+	anonymizeOwners = flag.Bool("anonymize-owners", false, "Show owners in guild reports as Player-1, Player-2... in the order the users were given; batch mode writes the mapping to guild-owners.csv")
+
+	rateLimitRetries    = flag.Int("rate-limit-retries", 2, "Times to retry a fetch that swgoh.gg rate limits before giving up")
+	maxIdleConns        = flag.Int("max-idle-conns", 100, "Most idle connections to upstream kept open for reuse (0 is unlimited)")
+	maxIdleConnsPerHost = flag.Int("max-idle-conns-per-host", 16, "Most idle connections kept per upstream host; at least -page-concurrency lets parallel page fetches reuse connections")
+	idleConnTimeout     = flag.Duration("idle-conn-timeout", 90*time.Second, "How long an idle upstream connection is kept before closing it")
+
+	maxPages          = flag.Int("max-pages", 0, "Most mods pages to scrape per user (0 scrapes them all)")
+	selectorsPath     = flag.String("selectors", "", "JSON file of CSS selectors to change from the defaults, e.g. {\"level\": \".mod-level\"}; the individual selector flags win over it")
+	modSelector       = flag.String("mod-selector", defaultSelectors.Mod, "CSS selector for each mod on a mods page")
+	statLabelSelector = flag.String("stat-label-selector", defaultSelectors.StatLabel, "CSS selector for a stat's name within a mod's primary or secondary stat")
+	lockedSelector    = flag.String("locked-selector", defaultSelectors.Locked, "CSS selector marking a mod as locked in game, on the mod element or inside it")
+	maxPageBytes      = flag.Int64("max-page-bytes", 4<<20, "Largest mods page response to parse, in bytes")
+	pageConcurrency   = flag.Int("page-concurrency", 8, "Most mods pages fetched and parsed at once per scrape")
+
+	cacheTTL        = flag.Duration("cache-ttl", 5*time.Minute, "How long a scrape is served from cache before it is refreshed (0 disables caching)")
+	scoredCacheSize = flag.Int("scored-cache-size", 64, "How many scored results to keep for reuse across scoring options (0 disables)")
+	cacheMaxStale   = flag.Duration("cache-max-stale", 30*time.Minute, "How long past -cache-ttl a cached scrape may still be served while it refreshes in the background")
+	maxSnapshots    = flag.Int("max-snapshots", 1000, "Most users whose last two scrapes are kept for /diff; past it the oldest is forgotten (0 is unlimited)")
+
+	resultMaxAge = flag.Duration("result-max-age", time.Minute, "Cache-Control max-age for mod pages and API responses")
+	staticMaxAge = flag.Duration("static-max-age", 24*time.Hour, "Cache-Control max-age for images and other static files")
+
+	breakerThreshold = flag.Int("breaker-threshold", 5, "Consecutive upstream failures before scraping is paused (0 disables)")
+	breakerCooldown  = flag.Duration("breaker-cooldown", 30*time.Second, "How long scraping stays paused before probing upstream again")
+
+	maxConcurrentScrapes = flag.Int("max-concurrent-scrapes", 0, "Most scrapes of swgoh.gg running at once across the server (0 is unlimited); cache hits don't count")
+	scrapeQueueWait      = flag.Duration("scrape-queue-wait", 5*time.Second, "How long a scrape waits for a free slot under -max-concurrent-scrapes before the request gets a 503")
 )
 
+// round rounds half away from zero (0.5 -> 1, -0.5 -> -1, 2.5 -> 3), the
+// same as math.Round, rather than half to even.
 func round(x float64) int {
 	t := math.Trunc(x)
 	if math.Abs(x-t) >= 0.5 {
@@ -82,8 +205,23 @@ func round(x float64) int {
 	return int(t)
 }
 
+func floor(x float64) int { return int(math.Floor(x)) }
+
+func ceil(x float64) int { return int(math.Ceil(x)) }
+
+// roundingModes are the -score-rounding choices for turning a secondary's
+// score into a whole number. floor never credits a stat with more than it
+// earned; ceil never with less.
+var roundingModes = map[string]func(float64) int{
+	"round": round,
+	"floor": floor,
+	"ceil":  ceil,
+}
+
+// parseStat reads a stat as the mods page shows it, e.g. "+10", "+1.5%"
+// or "+4,200". A trailing percent sign moves into the type as " %".
 func parseStat(rawType string, rawValue string) (Stat, error) {
-	statValueStr := strings.TrimPrefix(rawValue, "+")
+	statValueStr := strings.ReplaceAll(strings.TrimPrefix(rawValue, "+"), ",", "")
 	statType := rawType
 
 	if strings.HasSuffix(statValueStr, "%") {
@@ -94,123 +232,393 @@ func parseStat(rawType string, rawValue string) (Stat, error) {
 	statValue, err := strconv.ParseFloat(statValueStr, 64)
 
 	if err != nil {
-		log.Printf("Failed to parse stat value: %s", statValueStr)
 		return Stat{}, err
 	}
 
-	return Stat{statType, statValue}, nil
+	return Stat{statType, statValue, 0}, nil
 }
 
-func getPageCount(user string) (int, error) {
-	resp, err := http.Get(fmt.Sprintf("https://swgoh.gg/u/%s/mods/", user))
+func fetchPage(ctx context.Context, user string, page int) (*goquery.Document, error) {
+	resp, err := httpGet(ctx, strings.TrimSuffix(*baseURL, "/")+fmt.Sprintf(*pagePath, user, page))
 	if err != nil {
-		log.Fatal("Failed to fetch mods: ", err)
+		return nil, fmt.Errorf("failed to fetch mods page %d: %w", page, err)
 	}
 	defer resp.Body.Close()
 
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	if ct := resp.Header.Get("Content-Type"); ct != "" && !strings.HasPrefix(ct, "text/html") {
+		return nil, fmt.Errorf("mods page %d is %s, not HTML", page, ct)
+	}
+
+	body := &io.LimitedReader{R: resp.Body, N: *maxPageBytes + 1}
+
+	doc, err := goquery.NewDocumentFromReader(body)
 
 	if err != nil {
-		log.Fatal(err)
+		return nil, fmt.Errorf("failed to parse mods page %d: %w", page, err)
+	}
+
+	if body.N == 0 {
+		loggerFrom(ctx).Warn("Page exceeded size limit", "page", page, "limit", *maxPageBytes)
+		return nil, fmt.Errorf("mods page %d is larger than %d bytes", page, *maxPageBytes)
 	}
 
-	pageText := doc.Find(".pull-right .pagination li a").First().Text()
+	return doc, nil
+}
+
+func getPageCount(logger *slog.Logger, doc *goquery.Document) (int, error) {
+	pageText := doc.Find(selectors.Pagination).First().Text()
 
-	log.Printf("Found page text %s", pageText)
+	logger.Info("Found page text", "text", pageText)
+
+	// Users with a single page of mods get no pagination at all.
+	if strings.TrimSpace(pageText) == "" {
+		return 1, nil
+	}
 
-	r := regexp.MustCompile("Page [0-9]+ of ([0-9]+)")
+	match := pageCountRegexp.FindStringSubmatch(pageText)
+	if len(match) != 2 {
+		return 0, fmt.Errorf("unrecognised pagination text %q", pageText)
+	}
 
-	return strconv.Atoi(r.FindStringSubmatch(pageText)[1])
+	return strconv.Atoi(match[1])
 }
 
-func getMods(user string) []*Mod {
-	var mods []*Mod
-	var secondaryScoreMap = make(map[string]*SecondaryScore)
+// characterName reads the equipped character from a portrait. The name is
+// normally in title, but tooltips move it to data-original-title and some
+// portraits (ships, galactic legends) only carry it as image alt text.
+func characterName(portrait *goquery.Selection) string {
+	for _, attr := range []string{"title", "data-original-title", "data-title", "data-name", "alt"} {
+		if name, ok := portrait.Attr(attr); ok && strings.TrimSpace(name) != "" {
+			return strings.TrimSpace(name)
+		}
+	}
 
-	modChan := make(chan *Mod)
+	if alt, ok := portrait.Find("img").First().Attr("alt"); ok {
+		return strings.TrimSpace(alt)
+	}
 
-	pageCount, err := getPageCount(user)
+	return ""
+}
+
+// splitStatValue returns the text of the mod's own value within stat, with
+// any character-relative value taken out, and the character-relative text
+// on its own, so only the mod's value is ever scored.
+func splitStatValue(stat *goquery.Selection) (generic, contextual string) {
+	v := stat.Find(selectors.StatValue).Not(selectors.ContextualValue).First()
+	contextual = stat.Find(selectors.ContextualValue).First().Text()
+
+	if v.Find(selectors.ContextualValue).Length() > 0 {
+		v = v.Clone()
+		v.Find(selectors.ContextualValue).Remove()
+	}
+
+	return strings.TrimSpace(v.Text()), contextual
+}
+
+// parseContextual reads a character-relative value, which may be shown in
+// parentheses, giving 0 when there is none or it can't be read; it is only
+// ever shown, so it never fails a mod.
+func parseContextual(statType, raw string) float64 {
+	raw = strings.Trim(raw, " \t\n()")
+	if raw == "" {
+		return 0
+	}
 
+	stat, err := parseStat(statType, raw)
 	if err != nil {
-		log.Fatal("Failed to get page count", err)
+		return 0
 	}
 
-	var wg sync.WaitGroup
-	wg.Add(pageCount)
+	return stat.Value
+}
 
-	for i := 1; i < pageCount+1; i++ {
-		go func(page int) {
-			defer wg.Done()
+// checkContextual fails a character-relative value that -strict says must
+// be readable: one that was shown but parsed to nothing.
+func checkContextual(value float64, raw string) error {
+	if *strict && value == 0 && strings.Trim(raw, " \t\n()") != "" {
+		return fmt.Errorf("bad character value %q", raw)
+	}
+	return nil
+}
 
-			resp, err := http.Get(fmt.Sprintf("https://swgoh.gg/u/%s/mods/?page=%d", user, page))
-			if err != nil {
-				log.Fatal("Failed to fetch mods: ", err)
-			}
-			defer resp.Body.Close()
+func parseMod(s *goquery.Selection) (*Mod, error) {
+	modUid, ok := s.Attr("data-id")
+	if !ok {
+		return nil, errors.New("missing mod id")
+	}
 
-			doc, err := goquery.NewDocumentFromReader(resp.Body)
+	imageSrcAttr, ok := s.Find(selectors.Image).First().Attr("src")
+	if !ok {
+		return nil, fmt.Errorf("mod %s: missing image", modUid)
+	}
 
-			if err != nil {
-				log.Fatal(err)
+	imageMatch := modImageRegexp.FindStringSubmatch(imageSrcAttr)
+	if imageMatch == nil {
+		return nil, fmt.Errorf("mod %s: unrecognised image %q", modUid, imageSrcAttr)
+	}
+
+	set := lookupCode(modSetMap, "set", imageMatch[1])
+	slot := lookupCode(modSlotMap, "slot", imageMatch[2])
+
+	pips := s.Find(selectors.Pip).Size()
+
+	levelText := s.Find(selectors.Level).First().Text()
+	level, err := strconv.Atoi(strings.TrimSpace(levelText))
+
+	if err != nil {
+		return nil, fmt.Errorf("mod %s: bad level %q", modUid, levelText)
+	}
+
+	upgradeCostText := s.Find(selectors.UpgradeCost).First().Text()
+	upgradeCost := parseUpgradeCost(upgradeCostText)
+
+	portrait := s.Find(selectors.Portrait).First()
+	character := characterName(portrait)
+
+	if *strict {
+		switch {
+		case set == "":
+			return nil, fmt.Errorf("mod %s: unknown set code %s", modUid, imageMatch[1])
+		case slot == "":
+			return nil, fmt.Errorf("mod %s: unknown slot code %s", modUid, imageMatch[2])
+		case portrait.Length() > 0 && character == "":
+			return nil, fmt.Errorf("mod %s: character portrait without a name", modUid)
+		case strings.TrimSpace(upgradeCostText) != "" && upgradeCost == 0:
+			return nil, fmt.Errorf("mod %s: bad upgrade cost %q", modUid, upgradeCostText)
+		}
+	}
+
+	primaryStatType := s.Find(selectors.Primary).Find(selectors.StatLabel).First().Text()
+	primaryStatValueRaw, primaryContextual := splitStatValue(s.Find(selectors.Primary))
+
+	primaryStat, err := parseStat(primaryStatType, primaryStatValueRaw)
+
+	if err != nil {
+		return nil, fmt.Errorf("mod %s: bad primary stat: %v", modUid, err)
+	}
+	primaryStat.Contextual = parseContextual(primaryStatType, primaryContextual)
+	if err := checkContextual(primaryStat.Contextual, primaryContextual); err != nil {
+		return nil, fmt.Errorf("mod %s: primary stat: %v", modUid, err)
+	}
+
+	var secondaryStats []*SecondaryStat
+	var secondaryErr error
+
+	s.Find(selectors.Secondary).EachWithBreak(func(i int, statNode *goquery.Selection) bool {
+		secondaryStatType := statNode.Find(selectors.StatLabel).First().Text()
+		secondaryStatValueRaw, secondaryContextual := splitStatValue(statNode)
+
+		// Secondaries that haven't been revealed yet may be rendered as
+		// empty slots; they count the same as missing ones.
+		if strings.TrimSpace(secondaryStatType) == "" && strings.TrimSpace(secondaryStatValueRaw) == "" {
+			return true
+		}
+
+		stat, err := parseStat(secondaryStatType, secondaryStatValueRaw)
+
+		if err != nil {
+			secondaryErr = fmt.Errorf("mod %s: bad secondary stat: %v", modUid, err)
+			return false
+		}
+		stat.Contextual = parseContextual(secondaryStatType, secondaryContextual)
+		if err := checkContextual(stat.Contextual, secondaryContextual); err != nil {
+			secondaryErr = fmt.Errorf("mod %s: secondary stat: %v", modUid, err)
+			return false
+		}
+
+		secondaryStats = append(secondaryStats, &SecondaryStat{stat, 0})
+		return true
+	})
+
+	if secondaryErr != nil {
+		return nil, secondaryErr
+	}
+
+	mod := Mod{
+		Uid:                 modUid,
+		Slot:                slot,
+		Set:                 set,
+		Level:               level,
+		UpgradeCost:         upgradeCost,
+		Pips:                pips,
+		CharacterName:       character,
+		Equipped:            character != "",
+		Locked:              s.Is(selectors.Locked) || s.Find(selectors.Locked).Length() > 0,
+		PrimaryStat:         PrimaryStat{primaryStat},
+		SecondaryStats:      secondaryStats,
+		RevealedSecondaries: len(secondaryStats),
+		IsSpeedArrow:        slot == "arrow" && primaryStat.Type == "Speed",
+		PrimaryMaxed:        primaryMaxed(pips, primaryStat),
+	}
+
+	return &mod, nil
+}
+
+// parseUpgradeCost reads the credits needed to level a mod, e.g. "1,250".
+// Not every page shows it, so anything missing or unreadable counts as 0.
+func parseUpgradeCost(raw string) int {
+	cost, err := strconv.Atoi(strings.ReplaceAll(strings.TrimSpace(raw), ",", ""))
+	if err != nil {
+		return 0
+	}
+	return cost
+}
+
+func qualifies(mod *Mod) bool {
+	return mod.Level >= *minLevel && mod.Pips >= *minPips && mod.RevealedSecondaries >= *minSecondaries
+}
+
+func addToBounds(secondaryScoreMap map[string]*SecondaryScore, mod *Mod) {
+	for _, stat := range mod.SecondaryStats {
+		if val, ok := secondaryScoreMap[stat.Type]; ok {
+			val.Max = math.Max(val.Max, stat.Value)
+			val.Min = math.Min(val.Min, stat.Value)
+			val.Values = append(val.Values, stat.Value)
+		} else {
+			secondaryScoreMap[stat.Type] = &SecondaryScore{stat.Type, stat.Value, stat.Value, []float64{stat.Value}}
+		}
+	}
+}
+
+// parsePage sends each mod on page to modChan and returns how many mod
+// elements it found. It only parses: the scoring bounds are worked out once
+// every page is in. A mod that fails to parse is skipped, or under -strict
+// stops the page with an error.
+func parsePage(logger *slog.Logger, doc *goquery.Document, page int, modChan chan<- *Mod) (int, error) {
+	found := doc.Find(selectors.Mod)
+	var strictErr error
+	found.EachWithBreak(func(i int, s *goquery.Selection) bool {
+		mod, err := parseMod(s)
+
+		if err != nil {
+			if *strict {
+				strictErr = parseError{fmt.Errorf("mods page %d: %w", page, err)}
+				return false
 			}
+			logger.Warn("Skipping mod", "page", page, "err", err)
+			return true
+		}
+
+		modChan <- mod
+		return true
+	})
+	return found.Length(), strictErr
+}
+
+var errNoModsParsed = errors.New("no mods could be parsed, the swgoh.gg markup or API may have changed")
+
+// parseError is a scrape that failed on what upstream sent, e.g. a mod
+// -strict won't accept, rather than on getting it.
+type parseError struct {
+	err error
+}
+
+func (e parseError) Error() string { return e.err.Error() }
+func (e parseError) Unwrap() error { return e.err }
+
+// isParseError says whether err came from reading a page rather than
+// fetching it. Those are about one user's mods, not upstream's health.
+func isParseError(err error) bool {
+	var pe parseError
+	return errors.Is(err, errNoModsParsed) || errors.As(err, &pe)
+}
+
+type ScrapeResult struct {
+	Mods              []*Mod
+	SecondaryScoreMap map[string]*SecondaryScore
+	PageCount         int
+	Partial           bool
+	Stale             bool
+}
+
+var modSources = map[string]func(context.Context, string, progressFunc) (*ScrapeResult, error){
+	"html":   scrapeMods,
+	"ggjson": fetchGGJSONMods,
+}
+
+func scrapeMods(ctx context.Context, user string, progress progressFunc) (*ScrapeResult, error) {
+	logger := loggerFrom(ctx)
+	modChan := make(chan *Mod)
+
+	firstPage, err := fetchPage(ctx, user, 1)
+
+	if err != nil {
+		return nil, err
+	}
+
+	pageCount, err := getPageCount(logger, firstPage)
 
-			r := regexp.MustCompile("statmodmystery_([0-9])_([0-9]).png")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get page count: %v", err)
+	}
 
-			doc.Find(".collection-mod").Each(func(i int, s *goquery.Selection) {
-				modUid, _ := s.Attr("data-id")
+	partial := false
+	if *maxPages > 0 && pageCount > *maxPages {
+		logger.Info("Limiting pages scraped", "pages", pageCount, "max", *maxPages)
+		pageCount = *maxPages
+		partial = true
+	}
 
-				var set string
-				var slot string
-				if imageSrcAttr, ok := s.Find(".statmod-img").First().Attr("src"); ok {
-					set = modSetMap[r.FindStringSubmatch(imageSrcAttr)[1]]
-					slot = modSlotMap[r.FindStringSubmatch(imageSrcAttr)[2]]
-				}
+	// Every page but the last is full, so this is close to the final size
+	// and saves regrowing the slice for accounts with thousands of mods.
+	mods := make([]*Mod, 0, pageCount*firstPage.Find(selectors.Mod).Length())
 
-				pips := s.Find(".statmod-pip").Size()
+	// Parsed pages are the bulk of a scrape's memory, so only a few are
+	// fetched and held at once.
+	sem := make(chan struct{}, *pageConcurrency)
 
-				level, _ := strconv.Atoi(s.Find(".statmod-level").First().Text())
+	var wg sync.WaitGroup
+	var errOnce sync.Once
+	var pageErr error
+	var modsFound atomic.Int64
 
-				character, _ := s.Find(".char-portrait").First().Attr("title")
+	// Pages finish in any order, so count them under a lock to keep the
+	// reported progress in step.
+	var progressMu sync.Mutex
+	pagesDone := 0
+	pageDone := func() {
+		progressMu.Lock()
+		defer progressMu.Unlock()
+		pagesDone++
+		progress.report("pages", pagesDone, pageCount)
+	}
 
-				primaryStatType := s.Find(".statmod-stats-1 .statmod-stat-label").First().Text()
-				primaryStatValueRaw := s.Find(".statmod-stats-1 .statmod-stat-value").First().Text()
+	wg.Add(pageCount)
 
-				primaryStat, _ := parseStat(primaryStatType, primaryStatValueRaw)
+	go func() {
+		defer wg.Done()
+		found, err := parsePage(logger, firstPage, 1, modChan)
+		modsFound.Add(int64(found))
 
-				var secondaryStats []*SecondaryStat
+		if err != nil {
+			errOnce.Do(func() { pageErr = err })
+			return
+		}
+		pageDone()
+	}()
 
-				s.Find(".statmod-stats-2 .statmod-stat").Each(func(i int, statNode *goquery.Selection) {
-					secondaryStatType := statNode.Find(".statmod-stat-label").First().Text()
-					secondaryStatValueRaw := statNode.Find(".statmod-stat-value").First().Text()
+	for i := 2; i < pageCount+1; i++ {
+		go func(page int) {
+			defer wg.Done()
 
-					stat, _ := parseStat(secondaryStatType, secondaryStatValueRaw)
+			sem <- struct{}{}
+			defer func() { <-sem }()
 
-					if level >= 12 && pips >= 4 {
-						if val, ok := secondaryScoreMap[stat.Type]; ok {
-							val.Max = math.Max(val.Max, stat.Value)
-							val.Min = math.Min(val.Min, stat.Value)
-						} else {
-							secondaryScoreMap[stat.Type] = &SecondaryScore{stat.Type, stat.Value, stat.Value}
-						}
-					}
+			doc, err := fetchPage(ctx, user, page)
 
-					secondaryStats = append(secondaryStats, &SecondaryStat{stat, 0})
-				})
+			if err != nil {
+				errOnce.Do(func() { pageErr = err })
+				return
+			}
 
-				mod := Mod{
-					modUid,
-					slot,
-					set,
-					level,
-					pips,
-					0,
-					character,
-					PrimaryStat{primaryStat},
-					secondaryStats,
-				}
+			found, err := parsePage(logger, doc, page, modChan)
+			modsFound.Add(int64(found))
 
-				modChan <- &mod
-			})
+			if err != nil {
+				errOnce.Do(func() { pageErr = err })
+				return
+			}
+			pageDone()
 		}(i)
 	}
 
@@ -223,53 +631,540 @@ func getMods(user string) []*Mod {
 		mods = append(mods, m)
 	}
 
-	for _, m := range mods {
-		totalScore := 0
-		for _, s := range m.SecondaryStats {
-			score := math.Max(0, (s.Value-secondaryScoreMap[s.Type].Min)/(secondaryScoreMap[s.Type].Max-secondaryScoreMap[s.Type].Min)*100)
-			s.Score = round(score)
-			totalScore += s.Score
+	if pageErr != nil {
+		return nil, pageErr
+	}
+
+	// An account with no mods at all has a single empty page. Mod elements
+	// that all fail to parse, or pages of nothing, mean the markup moved.
+	if len(mods) == 0 {
+		if modsFound.Load() > 0 || pageCount > 1 {
+			logger.Error("Pages scraped but no mods parsed, check the selectors against swgoh.gg", "user", user, "pages", pageCount, "elements", modsFound.Load())
+			return nil, errNoModsParsed
+		}
+		logger.Warn("Scrape found no mods", "user", user)
+	}
+
+	return &ScrapeResult{
+		Mods:      mods,
+		PageCount: pageCount,
+		Partial:   partial,
+	}, nil
+}
+
+var errTooBusy = errors.New("too many scrapes in progress, please try again shortly")
+
+// scrapeSlots caps how many scrapes run at once across the server. It is
+// nil when -max-concurrent-scrapes is 0.
+var scrapeSlots chan struct{}
+
+func fetchMods(ctx context.Context, user string, progress progressFunc) (*ScrapeResult, error) {
+	logger := loggerFrom(ctx)
+	if scrapeSlots != nil {
+		select {
+		case scrapeSlots <- struct{}{}:
+			defer func() { <-scrapeSlots }()
+		case <-time.After(*scrapeQueueWait):
+			logger.Warn("Scrape slots full", "user", user, "waited", *scrapeQueueWait)
+			return nil, errTooBusy
+		case <-ctx.Done():
+			return nil, ctx.Err()
 		}
-		m.TotalScore = totalScore
 	}
 
-	sort.Slice(mods, func(i, j int) bool {
-		return mods[i].TotalScore > mods[j].TotalScore
+	if !upstream.allow() {
+		return nil, errCircuitOpen
+	}
+
+	res, err := modSources[*modSource](ctx, user, progress)
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		// The caller went away; that says nothing about upstream.
+	case errors.Is(err, errUserNotFound), isParseError(err):
+		// Upstream answered; the answer just isn't usable.
+		upstream.record(nil)
+	default:
+		upstream.record(err)
+	}
+
+	if err != nil {
+		logger.Error("Scrape failed", "user", user, "err", err)
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func cloneMods(mods []*Mod) []*Mod {
+	clones := make([]*Mod, len(mods))
+	for i, m := range mods {
+		c := *m
+		c.SecondaryStats = make([]*SecondaryStat, len(m.SecondaryStats))
+		for j, s := range m.SecondaryStats {
+			sc := *s
+			c.SecondaryStats[j] = &sc
+		}
+		clones[i] = &c
+	}
+	return clones
+}
+
+// getMods scrapes and scores user's mods, logging with the logger ctx
+// carries and giving up when ctx is done.
+func getMods(ctx context.Context, user string, opts ScoringOptions) (*ScrapeResult, error) {
+	return getModsWithProgress(ctx, user, opts, nil)
+}
+
+func getModsWithProgress(ctx context.Context, user string, opts ScoringOptions, progress progressFunc) (out *ScrapeResult, err error) {
+	logger := loggerFrom(ctx)
+	start := time.Now()
+	status := cacheMiss
+	defer func() { logScrapeSummary(logger, user, time.Since(start), status, out, err) }()
+
+	cached, status, err := scrapes.get(ctx, user, progress)
+
+	if err != nil {
+		return nil, err
+	}
+
+	key, cacheable := scoredKey(user, opts)
+
+	if cacheable {
+		if hit, ok := scored.get(key, cached); ok {
+			res := *hit
+			res.Stale = status == cacheStale
+			return &res, nil
+		}
+	}
+
+	res := scoreResult(logger, user, cached, opts)
+	if cacheable {
+		scored.put(key, cached, res)
+	}
+	progress.report("scored", len(res.Mods), len(res.Mods))
+
+	out = new(ScrapeResult)
+	*out = *res
+	out.Stale = status == cacheStale
+	return out, nil
+}
+
+// logScrapeSummary writes the one line per getMods call that says how it
+// went, for capacity planning without a metrics stack.
+func logScrapeSummary(logger *slog.Logger, user string, took time.Duration, status cacheStatus, res *ScrapeResult, err error) {
+	if err != nil {
+		logger.Error("Scrape summary", "user", user, "took", took, "cache", status, "err", err)
+		return
+	}
+
+	pagesFetched := 0
+	if status == cacheMiss {
+		pagesFetched = res.PageCount
+	}
+
+	logger.Info("Scrape summary", "user", user, "took", took, "cache", status, "pages", pagesFetched, "mods", len(res.Mods), "partial", res.Partial)
+}
+
+// scoreResult scores and sorts a copy of a cached scrape. The result is
+// itself cached and shared, so callers must not modify its mods.
+func scoreResult(logger *slog.Logger, user string, cached *ScrapeResult, opts ScoringOptions) *ScrapeResult {
+	res := *cached
+	res.Mods = cloneMods(cached.Mods)
+
+	mods := res.Mods
+
+	if opts.Disabled {
+		sort.Slice(mods, func(i, j int) bool {
+			if mods[i].Slot != mods[j].Slot {
+				return slotIndex(mods[i].Slot) < slotIndex(mods[j].Slot)
+			}
+			return mods[i].CharacterName < mods[j].CharacterName
+		})
+		return &res
+	}
+
+	// Qualification is a scoring choice, so the bounds are worked out here
+	// from the scraped mods rather than taken from the scrape.
+	var allMods bool
+	res.SecondaryScoreMap, allMods = scoringPopulation(cached.Mods, opts.qualify)
+	if allMods {
+		logger.Debug("Too few mods qualify for scoring, using all mods", "user", user, "threshold", *minQualifying)
+	}
+
+	scoreMods(mods, res.SecondaryScoreMap, opts)
+
+	sort.SliceStable(mods, func(i, j int) bool {
+		if mods[i].TotalScore != mods[j].TotalScore {
+			return mods[i].TotalScore > mods[j].TotalScore
+		}
+		return mods[i].Uid < mods[j].Uid
 	})
 
+	rankMods(mods)
+
 	for _, m := range mods {
-		log.Printf("Score: %f, Uid: %v, Slot: %v, Type: %v, Pips: %v, Level: %v, Character: %v, Pri Type: %v, Pri Value: %v", m.TotalScore, m.Uid, m.Slot, m.Set, m.Pips, m.Level, m.CharacterName, m.PrimaryStat.Type, m.PrimaryStat.Value)
+		logger.Debug("Scored mod", "score", m.TotalScore, "uid", m.Uid, "slot", m.Slot, "set", m.Set, "pips", m.Pips, "level", m.Level, "character", m.CharacterName, "primaryType", m.PrimaryStat.Type, "primaryValue", m.PrimaryStat.Value)
+	}
+
+	return &res
+}
+
+// rankMods numbers score-sorted mods using standard competition ranking:
+// equal scores share a rank and the next rank skips, e.g. 1, 2, 2, 4.
+func rankMods(mods []*Mod) {
+	for i, m := range mods {
+		if i > 0 && m.TotalScore == mods[i-1].TotalScore {
+			m.Rank = mods[i-1].Rank
+		} else {
+			m.Rank = i + 1
+		}
 	}
+}
+
+func requestLogger(r *http.Request) *slog.Logger {
+	id := make([]byte, 4)
+	rand.Read(id)
+
+	logger := slog.With("req", hex.EncodeToString(id))
+	logger.Info("Serving", "url", r.URL.String())
 
-	return mods
+	return logger
 }
 
+//go:embed static/favicon.ico
+var faviconICO []byte
+
 func favicon(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusNotFound)
+	w.Header().Set("Content-Type", "image/x-icon")
+	setCacheControl(w, *staticMaxAge)
+	w.Write(faviconICO)
 }
 
 type ModData struct {
-	Mods []*Mod
+	User         string
+	Filter       modFilter
+	Error        string
+	Partial      bool
+	Stale        bool
+	Mods         []*Mod
+	TotalCount   int
+	Unassigned   int
+	AverageScore float64
+	MaxScore     int
+	PipCounts    map[int]int
+	Sparklines   []Sparkline
+}
+
+func (ModData) PrimaryTypes() []string {
+	var types []string
+	for t := range primaryStatTypes {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+func (ModData) Slots() []string {
+	var slots []string
+	for _, s := range modSlotMap {
+		slots = append(slots, s)
+	}
+	sort.Slice(slots, func(i, j int) bool {
+		return slotIndex(slots[i]) < slotIndex(slots[j])
+	})
+	return slots
+}
+
+func newModData(mods []*Mod) ModData {
+	data := ModData{
+		Mods:       mods,
+		TotalCount: len(mods),
+		PipCounts:  make(map[int]int),
+		Sparklines: sparklines(mods),
+	}
+
+	totalScore := 0
+	for _, m := range mods {
+		totalScore += m.TotalScore
+		if m.TotalScore > data.MaxScore {
+			data.MaxScore = m.TotalScore
+		}
+		data.PipCounts[m.Pips]++
+		if m.CharacterName == "" {
+			data.Unassigned++
+		}
+	}
+
+	if len(mods) > 0 {
+		data.AverageScore = presentScore(totalScore) / float64(len(mods))
+	}
+
+	return data
+}
+
+// parseTemplate loads the page template along with the partials (header,
+// mod, footer) defined in the other .html files of its directory.
+func parseTemplate() (*template.Template, error) {
+	return template.New(filepath.Base(*templatePath)).Funcs(template.FuncMap{
+		"score":       formatScore,
+		"secondaries": displaySecondaries,
+	}).ParseGlob(filepath.Join(filepath.Dir(*templatePath), "*.html"))
+}
+
+// displaySecondaries orders secondaries for the page. With -group-stats,
+// e.g. Offense and Offense % are listed together, where the first of them
+// appeared; otherwise the mod's own order is kept.
+func displaySecondaries(stats []*SecondaryStat) []*SecondaryStat {
+	if !*groupStats {
+		return stats
+	}
+
+	first := make(map[string]int, len(stats))
+	for i, s := range stats {
+		base := strings.TrimSuffix(s.Type, " %")
+		if _, ok := first[base]; !ok {
+			first[base] = i
+		}
+	}
+
+	grouped := append([]*SecondaryStat(nil), stats...)
+	sort.SliceStable(grouped, func(i, j int) bool {
+		return first[strings.TrimSuffix(grouped[i].Type, " %")] < first[strings.TrimSuffix(grouped[j].Type, " %")]
+	})
+
+	return grouped
+}
+
+func renderError(w http.ResponseWriter, tmpl *template.Template, status int, msg string) {
+	w.WriteHeader(status)
+	tmpl.Execute(w, ModData{Error: msg})
 }
 
 func main() {
-	tmpl := template.Must(template.ParseFiles("static/index.html"))
+	flag.Parse()
+
+	if _, ok := scoreMethods[*scoreMethod]; !ok {
+		log.Fatalf("Unknown score method %q", *scoreMethod)
+	}
+
+	if *pageConcurrency < 1 {
+		log.Fatal("-page-concurrency must be at least 1")
+	}
+
+	if _, ok := sortOrders[*defaultOrder]; !ok {
+		log.Fatalf("Unknown default order %q", *defaultOrder)
+	}
+
+	if *speedWeight < 0 {
+		log.Fatalf("Speed weight %v can't be negative", *speedWeight)
+	}
+
+	if _, ok := aggregates[*aggregateMode]; !ok {
+		log.Fatalf("Unknown aggregate %q, want sum or avg", *aggregateMode)
+	}
+
+	if _, ok := roundingModes[*scoreRounding]; !ok {
+		log.Fatalf("Unknown score rounding %q, want round, floor or ceil", *scoreRounding)
+	}
+
+	if !validScoreScales[*scoreScale] {
+		log.Fatalf("Unsupported score scale %v", *scoreScale)
+	}
+
+	var err error
+	if gradeThresholds, err = parseGradeThresholds(*grades); err != nil {
+		log.Fatal(err)
+	}
+
+	if *selectorsPath != "" {
+		var err error
+		if selectors, err = loadSelectors(*selectorsPath); err != nil {
+			log.Fatal("Failed to load selectors: ", err)
+		}
+	}
+	applySelectorFlags()
+
+	if *modCodesPath != "" {
+		if err := loadModCodes(*modCodesPath); err != nil {
+			log.Fatal("Failed to load mod codes: ", err)
+		}
+	}
+
+	if *baselinePath != "" {
+		if baseline, err = loadBaseline(*baselinePath); err != nil {
+			log.Fatal("Failed to load baseline: ", err)
+		}
+	}
+
+	if _, ok := modSources[*modSource]; !ok {
+		log.Fatalf("Unknown source %q", *modSource)
+	}
+
+	if _, ok := inputFormats[*inputFormat]; !ok {
+		log.Fatalf("Unknown input format %q", *inputFormat)
+	}
+
+	if p := fmt.Sprintf(*pagePath, "user", 1); strings.Contains(p, "%!") {
+		log.Fatalf("Bad page path %q: it needs one %%s for the username then one %%d for the page", *pagePath)
+	}
+
+	if *maxIdleConns < 0 || *maxIdleConnsPerHost < 0 {
+		log.Fatal("-max-idle-conns and -max-idle-conns-per-host can't be negative")
+	}
+
+	upstreamClient = newUpstreamClient()
+	upstream = newCircuitBreaker(*breakerThreshold, *breakerCooldown)
+	if *inputPath != "" {
+		mods, err := readInputMods(slog.Default(), *inputPath, *inputFormat)
+		if err != nil {
+			log.Fatal(err)
+		}
+		opts, _ := scoringOptions(nil)
+		res := scoreResult(slog.Default(), *inputPath, &ScrapeResult{Mods: mods}, opts)
+		if err := writeModsNDJSON(os.Stdout, res.Mods, nil); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if *ndjsonUser != "" {
+		opts, _ := scoringOptions(nil)
+		res, err := getMods(context.Background(), normalizeUser(*ndjsonUser), opts)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := writeModsNDJSON(os.Stdout, res.Mods, nil); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if *usersFile != "" {
+		users, err := readUsersFile(*usersFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := os.MkdirAll(*outDir, 0o755); err != nil {
+			log.Fatal(err)
+		}
+		if runBatch(slog.Default(), users, *outDir) > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *selftest {
+		if !runSelftest(slog.Default(), os.Stdout) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *validateUser != "" {
+		if !validateScrape(slog.Default(), os.Stdout, normalizeUser(*validateUser)) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *maxConcurrentScrapes > 0 {
+		scrapeSlots = make(chan struct{}, *maxConcurrentScrapes)
+	}
+
+	snapshots = newSnapshotStore(*maxSnapshots)
+	scrapes = newScrapeCache(*cacheTTL, *cacheMaxStale, fetchMods)
+	scrapes.stored = snapshots.put
+	scored = newScoredCache(*scoredCacheSize)
+
+	pageTmpl, err := parseTemplate()
+
+	if err != nil {
+		log.Fatalf("Failed to parse template %s: %v", *templatePath, err)
+	}
 
 	fs := http.FileServer(http.Dir("static/resources"))
-	http.Handle("/resources/", http.StripPrefix("/resources/", fs))
+	http.Handle("/resources/", http.StripPrefix("/resources/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		setCacheControl(w, *staticMaxAge)
+		fs.ServeHTTP(w, r)
+	})))
 
 	http.HandleFunc("/favicon.ico", favicon)
+	http.HandleFunc("/api/mods", apiMods)
+	http.HandleFunc("/top", apiTop)
+	http.HandleFunc("/totals", apiTotals)
+	http.HandleFunc("/stats", apiStats)
+	http.HandleFunc("/diff", apiDiff)
+	http.HandleFunc("/sell", apiSell)
+	http.HandleFunc("/invest", apiInvest)
+	http.HandleFunc("/best", apiBest)
+	http.HandleFunc("/loadout", apiLoadout)
+	http.HandleFunc("/bounds", apiBounds)
+	http.HandleFunc("/mod", apiMod)
+	http.HandleFunc("/guild", apiGuild)
+	http.HandleFunc("/events", apiEvents)
+	http.HandleFunc("/whatif", apiWhatIf)
 
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("Serving %s", r.URL.String())
-		user := r.URL.Query().Get("u")
+		if prefersJSON(r.Header.Get("Accept")) {
+			apiMods(w, r)
+			return
+		}
 
-		if user != "" {
-			mods := getMods(user)
-			tmpl.Execute(w, ModData{Mods: mods})
-		} else {
-			w.WriteHeader(http.StatusBadRequest)
+		logger := requestLogger(r)
+		user := userParam(r.URL.Query())
+
+		tmpl := pageTmpl
+		if *devMode {
+			t, err := parseTemplate()
+			if err != nil {
+				logger.Error("Failed to parse template", "err", err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			tmpl = t
+		}
+
+		if user == "" {
+			tmpl.Execute(w, ModData{Filter: modFilter{Ascending: sortOrders[*defaultOrder]}})
+			return
+		}
+
+		filter, err := parseModFilter(r.URL.Query())
+
+		if err != nil {
+			logger.Warn("Bad filter", "err", err)
+			renderError(w, tmpl, http.StatusBadRequest, err.Error())
+			return
 		}
+
+		opts, err := scoringOptions(r.URL.Query())
+
+		if err != nil {
+			renderError(w, tmpl, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		res, err := getMods(withLogger(r.Context(), logger), user, opts)
+
+		if errors.Is(err, errUserNotFound) {
+			renderError(w, tmpl, http.StatusNotFound, fmt.Sprintf("Couldn't find a swgoh.gg user named %q.", user))
+			return
+		}
+
+		if err != nil {
+			renderError(w, tmpl, scrapeErrorStatus(err), "Couldn't fetch mods from swgoh.gg right now, please try again later.")
+			return
+		}
+
+		data := newModData(filter.apply(res.Mods))
+		data.User = user
+		data.Filter = filter
+		data.Partial = res.Partial
+		data.Stale = res.Stale
+
+		setResultHeaders(w, res)
+		tmpl.Execute(w, data)
 	})
 
 	log.Printf("Starting Mod Manager on port %d", *httpPort)
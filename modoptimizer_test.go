@@ -0,0 +1,320 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestRound(t *testing.T) {
+	for _, tc := range []struct {
+		x    float64
+		want int
+	}{
+		{0, 0},
+		{0.5, 1},
+		{1.5, 2},
+		{2.5, 3},
+		{-0.5, -1},
+		{-1.5, -2},
+		{-2.4, -2},
+		{0.4999999, 0},
+		{2.5000001, 3},
+		{1e15 + 0.5, 1e15 + 1},
+	} {
+		if got := round(tc.x); got != tc.want {
+			t.Errorf("round(%v) = %d, want %d", tc.x, got, tc.want)
+		}
+	}
+}
+
+func TestRoundingModes(t *testing.T) {
+	for _, tc := range []struct {
+		x                  float64
+		round, floor, ceil int
+	}{
+		{42, 42, 42, 42},
+		{42.5, 43, 42, 43},
+		{42.4999, 42, 42, 43},
+		{100, 100, 100, 100},
+	} {
+		for mode, want := range map[string]int{"round": tc.round, "floor": tc.floor, "ceil": tc.ceil} {
+			if got := roundingModes[mode](tc.x); got != want {
+				t.Errorf("%s(%v) = %d, want %d", mode, tc.x, got, want)
+			}
+		}
+	}
+}
+
+func TestParseStat(t *testing.T) {
+	for _, tc := range []struct {
+		value string
+		want  Stat
+	}{
+		{"+10", Stat{"Offense", 10, 0}},
+		{"+10%", Stat{"Offense %", 10, 0}},
+		{"25", Stat{"Offense", 25, 0}},
+		{"+4,200", Stat{"Offense", 4200, 0}},
+	} {
+		got, err := parseStat("Offense", tc.value)
+		if err != nil {
+			t.Errorf("parseStat(%q): %v", tc.value, err)
+			continue
+		}
+		if got.Type != tc.want.Type || got.Value != tc.want.Value {
+			t.Errorf("parseStat(%q) = %s %v, want %s %v", tc.value, got.Type, got.Value, tc.want.Type, tc.want.Value)
+		}
+	}
+
+	for _, value := range []string{"", "abc", "+%", "1.2.3"} {
+		if got, err := parseStat("Offense", value); err == nil {
+			t.Errorf("parseStat(%q) = %v, want an error", value, got)
+		}
+	}
+}
+
+// serveFixturePages serves the selftest fixture as every page of a
+// pages-long mods collection.
+func serveFixturePages(t testing.TB, pages int) {
+	t.Helper()
+
+	servePage(t, bytes.Replace(selftestHTML, []byte("Page 1 of 1"), []byte(fmt.Sprintf("Page 1 of %d", pages)), 1))
+}
+
+// servePage serves page as every mods page.
+func servePage(t testing.TB, page []byte) {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(page)
+	}))
+
+	saved := *baseURL
+	*baseURL = srv.URL
+	t.Cleanup(func() {
+		*baseURL = saved
+		srv.Close()
+	})
+}
+
+// BenchmarkScrapeLarge scrapes a 5000-mod collection, 625 pages of the
+// selftest fixture, to measure what parsing allocates.
+func BenchmarkScrapeLarge(b *testing.B) {
+	serveFixturePages(b, 625)
+	b.ReportAllocs()
+
+	for b.Loop() {
+		res, err := fetchMods(context.Background(), "large", nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(res.Mods) != 5000 {
+			b.Fatalf("got %d mods, want 5000", len(res.Mods))
+		}
+	}
+}
+
+// TestGetModsConcurrent scrapes many pages from several goroutines at
+// once, for go test -race to check the page workers and scoring.
+func TestGetModsConcurrent(t *testing.T) {
+	const pages, scrapers = 20, 8
+	serveFixturePages(t, pages)
+
+	opts, err := scoringOptions(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := make([]*ScrapeResult, scrapers)
+	errs := make([]error, scrapers)
+
+	var wg sync.WaitGroup
+	for i := range scrapers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = getMods(context.Background(), fmt.Sprintf("user%d", i), opts)
+		}()
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("scraper %d: %v", i, err)
+		}
+	}
+
+	want := pages * bytes.Count(selftestHTML, []byte(`class="collection-mod`))
+	first := results[0]
+	if len(first.Mods) != want {
+		t.Fatalf("scraper 0: got %d mods, want %d", len(first.Mods), want)
+	}
+	if len(first.SecondaryScoreMap) == 0 {
+		t.Fatal("scraper 0: got no scoring bounds")
+	}
+
+	// Every scraper read the same pages, so all must agree on the bounds
+	// and on every score.
+	for i, res := range results[1:] {
+		if len(res.Mods) != want {
+			t.Errorf("scraper %d: got %d mods, want %d", i+1, len(res.Mods), want)
+			continue
+		}
+
+		if len(res.SecondaryScoreMap) != len(first.SecondaryScoreMap) {
+			t.Errorf("scraper %d: got bounds for %d stats, want %d", i+1, len(res.SecondaryScoreMap), len(first.SecondaryScoreMap))
+		}
+		for statType, b := range first.SecondaryScoreMap {
+			got, ok := res.SecondaryScoreMap[statType]
+			if !ok || got.Min != b.Min || got.Max != b.Max {
+				t.Errorf("scraper %d: %s bounds %v, want %v-%v", i+1, statType, got, b.Min, b.Max)
+			}
+		}
+
+		for j, m := range res.Mods {
+			w := first.Mods[j]
+			if m.Uid != w.Uid || m.TotalScore != w.TotalScore {
+				t.Errorf("scraper %d: mod %d is %s scoring %d, want %s scoring %d", i+1, j, m.Uid, m.TotalScore, w.Uid, w.TotalScore)
+				continue
+			}
+			for k, stat := range m.SecondaryStats {
+				if stat.Score != w.SecondaryStats[k].Score {
+					t.Errorf("scraper %d: %s %s scores %d, want %d", i+1, m.Uid, stat.Type, stat.Score, w.SecondaryStats[k].Score)
+				}
+			}
+		}
+	}
+}
+
+// parseModHTML parses a single mod element, as a mods page renders it.
+func parseModHTML(t *testing.T, html string) *Mod {
+	t.Helper()
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := parseMod(doc.Find(selectors.Mod).First())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func testModHTML(slotCode, primaryLabel, primaryValue string) string {
+	return `<div class="collection-mod" data-id="t-1">
+  <img class="statmod-img" src="/static/img/statmodmystery_4_` + slotCode + `.png">
+  <span class="statmod-pip"></span><span class="statmod-pip"></span><span class="statmod-pip"></span><span class="statmod-pip"></span><span class="statmod-pip"></span>
+  <span class="statmod-level">15</span>
+  <div class="statmod-stats-1"><span class="statmod-stat-label">` + primaryLabel + `</span><span class="statmod-stat-value">` + primaryValue + `</span></div>
+  <div class="statmod-stats-2">
+    <div class="statmod-stat"><span class="statmod-stat-label">Health</span><span class="statmod-stat-value">+560</span></div>
+  </div>
+</div>`
+}
+
+func TestIsSpeedArrow(t *testing.T) {
+	for _, tc := range []struct {
+		slotCode, primaryLabel, primaryValue string
+		want                                 bool
+	}{
+		{"2", "Speed", "+30", true},
+		{"2", "Offense", "+5.88%", false},
+		{"1", "Speed", "+30", false},
+		{"5", "Health", "+5.88%", false},
+	} {
+		m := parseModHTML(t, testModHTML(tc.slotCode, tc.primaryLabel, tc.primaryValue))
+		if m.IsSpeedArrow != tc.want {
+			t.Errorf("%s with a %s primary: IsSpeedArrow = %v, want %v", m.Slot, m.PrimaryStat.Type, m.IsSpeedArrow, tc.want)
+		}
+	}
+
+	for _, tc := range []struct {
+		slot, statID int
+		want         bool
+	}{
+		{2, 5, true},
+		{2, 48, false},
+		{1, 5, false},
+	} {
+		m, err := convertGGMod(ggMod{ID: "t-1", Slot: tc.slot, Set: 4, Rarity: 5, PrimaryStat: ggStat{"", tc.statID, "30"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.IsSpeedArrow != tc.want {
+			t.Errorf("swgoh.gg %s with a %s primary: IsSpeedArrow = %v, want %v", m.Slot, m.PrimaryStat.Type, m.IsSpeedArrow, tc.want)
+		}
+	}
+}
+
+func TestCharacterName(t *testing.T) {
+	for _, tc := range []struct {
+		portrait, want string
+	}{
+		{`<div class="char-portrait" title="Darth Vader"></div>`, "Darth Vader"},
+		{`<div class="char-portrait" title="" data-original-title=" Bossk "></div>`, "Bossk"},
+		{`<div class="char-portrait"><img alt="Grand Admiral Thrawn"></div>`, "Grand Admiral Thrawn"},
+		{`<img class="char-portrait" alt="Rey">`, "Rey"},
+		{`<div class="char-portrait"></div>`, ""},
+	} {
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(tc.portrait))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := characterName(doc.Find(selectors.Portrait).First()); got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.portrait, got, tc.want)
+		}
+	}
+}
+
+func TestParsePartiallyRevealedMod(t *testing.T) {
+	m := parseModHTML(t, `<div class="collection-mod" data-id="t-2">
+  <img class="statmod-img" src="/static/img/statmodmystery_1_3.png">
+  <span class="statmod-pip"></span><span class="statmod-pip"></span><span class="statmod-pip"></span><span class="statmod-pip"></span><span class="statmod-pip"></span>
+  <span class="statmod-level">6</span>
+  <div class="statmod-stats-1"><span class="statmod-stat-label">Defense</span><span class="statmod-stat-value">+5.88%</span></div>
+  <div class="statmod-stats-2">
+    <div class="statmod-stat"><span class="statmod-stat-label">Speed</span><span class="statmod-stat-value">+4</span></div>
+    <div class="statmod-stat"><span class="statmod-stat-label">Offense</span><span class="statmod-stat-value">+22</span></div>
+    <div class="statmod-stat"><span class="statmod-stat-label"></span><span class="statmod-stat-value"> </span></div>
+    <div class="statmod-stat"></div>
+  </div>
+</div>`)
+
+	if m.RevealedSecondaries != 2 || len(m.SecondaryStats) != 2 {
+		t.Fatalf("got %d revealed of %d secondaries, want 2 of 2", m.RevealedSecondaries, len(m.SecondaryStats))
+	}
+	if s := m.SecondaryStats[1]; s.Type != "Offense" || s.Value != 22 {
+		t.Errorf("got second secondary %s %v, want Offense 22", s.Type, s.Value)
+	}
+}
+
+func TestParseErrorsDontTripBreaker(t *testing.T) {
+	// The fixture's mod elements without their images: skipped when
+	// lenient, so nothing parses, and rejected under -strict.
+	servePage(t, bytes.ReplaceAll(selftestHTML, []byte(`class="statmod-img"`), []byte(`class="no-img"`)))
+
+	savedBreaker, savedStrict := upstream, *strict
+	t.Cleanup(func() { upstream, *strict = savedBreaker, savedStrict })
+	upstream = newCircuitBreaker(1, time.Hour)
+
+	for _, strictMode := range []bool{false, true} {
+		*strict = strictMode
+		if _, err := fetchMods(context.Background(), "alice", nil); err == nil {
+			t.Fatalf("strict %v: scrape of unparseable mods succeeded", strictMode)
+		}
+		if !upstream.allow() {
+			t.Fatalf("strict %v: a parse failure opened the breaker", strictMode)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+// primaryMaxValues holds the level 15 primary stat value for each pip
+// count. 6-dot mods get the higher values once a 5-dot mod is sliced up.
+var primaryMaxValues = map[int]map[string]float64{
+	5: {
+		"Speed":                30,
+		"Offense %":            5.88,
+		"Defense %":            11.75,
+		"Health %":             5.88,
+		"Protection %":         23.5,
+		"Critical Chance %":    12,
+		"Critical Damage %":    36,
+		"Critical Avoidance %": 24,
+		"Accuracy %":           12,
+		"Potency %":            24,
+		"Tenacity %":           24,
+	},
+	6: {
+		"Speed":                32,
+		"Offense %":            8.5,
+		"Defense %":            20,
+		"Health %":             16,
+		"Protection %":         24,
+		"Critical Chance %":    20,
+		"Critical Damage %":    42,
+		"Critical Avoidance %": 35,
+		"Accuracy %":           30,
+		"Potency %":            30,
+		"Tenacity %":           35,
+	},
+}
+
+// primaryMaxed reports whether a primary has reached the top value for the
+// mod's pips. Mods below 5 pips are never reported as maxed.
+func primaryMaxed(pips int, primary Stat) bool {
+	max, ok := primaryMaxValues[pips][primary.Type]
+	return ok && primary.Value >= max
+}
@@ -0,0 +1,88 @@
+package main
+
+import "fmt"
+
+// A profile weights each secondary's score by how much a kind of character
+// wants that stat. Stats a profile leaves out are worth nothing to it.
+type profile map[string]float64
+
+var profiles = map[string]profile{
+	"speed": {
+		"Speed": 1,
+	},
+	"offense": {
+		"Speed":             1,
+		"Offense":           0.75,
+		"Offense %":         0.75,
+		"Critical Chance %": 0.5,
+		"Potency %":         0.25,
+	},
+	"tank": {
+		"Speed":        1,
+		"Health":       0.5,
+		"Health %":     0.75,
+		"Protection":   0.5,
+		"Protection %": 0.75,
+		"Defense":      0.25,
+		"Defense %":    0.5,
+		"Tenacity %":   0.5,
+	},
+	"support": {
+		"Speed":        1,
+		"Potency %":    0.75,
+		"Tenacity %":   0.5,
+		"Health %":     0.5,
+		"Protection %": 0.5,
+	},
+}
+
+// balanced weights every stat equally, which ranks mods as their
+// TotalScore does.
+const balancedProfile = "balanced"
+
+func lookupProfile(name string) (profile, error) {
+	if name == "" || name == balancedProfile {
+		return nil, nil
+	}
+
+	p, ok := profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown profile %q", name)
+	}
+	return p, nil
+}
+
+// weight is how much the profile values a stat. -speed-weight sets Speed's
+// weight for the balanced profile and for any profile that doesn't weight
+// Speed itself; a profile's own Speed weight wins.
+func (p profile) weight(statType string) float64 {
+	if w, ok := p[statType]; ok {
+		return w
+	}
+	if statType == "Speed" {
+		return *speedWeight
+	}
+	return 0
+}
+
+// score is a mod's secondary scores weighted by the profile. A nil profile
+// is balanced.
+func (p profile) score(m *Mod) float64 {
+	if p == nil {
+		// Start from TotalScore so -aggregate still applies, adjusting
+		// only for the Speed weight.
+		total := float64(m.TotalScore)
+		for _, stat := range m.SecondaryStats {
+			if stat.Type == "Speed" {
+				total += (*speedWeight - 1) * float64(stat.Score)
+			}
+		}
+		return total
+	}
+
+	total := 0.0
+	for _, stat := range m.SecondaryStats {
+		total += p.weight(stat.Type) * float64(stat.Score)
+	}
+	return total
+}
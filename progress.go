@@ -0,0 +1,19 @@
+package main
+
+// Progress is a step in working out a user's mods, e.g. page 3 of 40
+// fetched.
+type Progress struct {
+	Stage string `json:"stage"`
+	Done  int    `json:"done"`
+	Total int    `json:"total"`
+}
+
+// progressFunc is told about each step of a scrape as it happens. A nil
+// progressFunc ignores them, so callers that don't care can pass nil.
+type progressFunc func(Progress)
+
+func (p progressFunc) report(stage string, done, total int) {
+	if p != nil {
+		p(Progress{stage, done, total})
+	}
+}
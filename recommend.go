@@ -0,0 +1,31 @@
+package main
+
+import (
+	"sort"
+)
+
+// sellCandidates returns the count lowest scoring mods, worst first. Mods
+// equipped on a character or locked in game are left out unless
+// includeEquipped or includeLocked is set, and count applies after that
+// filter.
+func sellCandidates(mods []*Mod, count int, includeEquipped, includeLocked bool) []*Mod {
+	var candidates []*Mod
+	for _, m := range mods {
+		if (includeEquipped || !m.Equipped) && (includeLocked || !m.Locked) {
+			candidates = append(candidates, m)
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].TotalScore != candidates[j].TotalScore {
+			return candidates[i].TotalScore < candidates[j].TotalScore
+		}
+		return candidates[i].Uid < candidates[j].Uid
+	})
+
+	if len(candidates) > count {
+		candidates = candidates[:count]
+	}
+
+	return candidates
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSellCandidatesEquipped(t *testing.T) {
+	mods := []*Mod{
+		{Uid: "worn", TotalScore: 10, Equipped: true, CharacterName: "Bossk"},
+		{Uid: "spare", TotalScore: 20},
+		{Uid: "locked", TotalScore: 5, Locked: true},
+	}
+
+	uids := func(mods []*Mod) []string {
+		var uids []string
+		for _, m := range mods {
+			uids = append(uids, m.Uid)
+		}
+		return uids
+	}
+
+	if got := uids(sellCandidates(mods, 10, false, false)); !slices.Equal(got, []string{"spare"}) {
+		t.Errorf("by default got %v, want only the unequipped, unlocked mod", got)
+	}
+	if got := uids(sellCandidates(mods, 10, true, false)); !slices.Equal(got, []string{"worn", "spare"}) {
+		t.Errorf("including equipped got %v, want worn then spare", got)
+	}
+}
@@ -0,0 +1,491 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+type ScoringOptions struct {
+	Method   string
+	Disabled bool
+	Baseline map[string]*SecondaryScore
+	// Qualifies picks the mods whose secondaries set the scoring bounds.
+	// nil means qualifies, the -min-level, -min-pips and -min-secondaries
+	// bar.
+	Qualifies func(*Mod) bool
+	// QualifyRule names Qualifies in the scored-cache key. Results scored
+	// with a Qualifies but no QualifyRule aren't cached, since there'd be
+	// no telling them apart from the default rule's.
+	QualifyRule string
+}
+
+func (o ScoringOptions) qualify(m *Mod) bool {
+	if o.Qualifies == nil {
+		return qualifies(m)
+	}
+	return o.Qualifies(m)
+}
+
+var baseline map[string]*SecondaryScore
+
+// aggregates are the -aggregate ways of combining a mod's secondary scores
+// into its TotalScore. sum rewards every revealed secondary, so a mod still
+// hiding some always trails a finished one however good its stats. avg
+// scales the mean back up to four secondaries, keeping totals and grades
+// on the same scale, so mods compare on quality whatever their level; the
+// cost is that a lucky lone secondary ranks as highly as four proven ones.
+var aggregates = map[string]func(total, count int) int{
+	"sum": func(total, _ int) int { return total },
+	"avg": func(total, count int) int {
+		if count == 0 {
+			return 0
+		}
+		return round(float64(total*maxSecondaries) / float64(count))
+	},
+}
+
+// minScoreChunk keeps small collections from being split across workers,
+// where starting the goroutines would cost more than scoring the mods.
+const minScoreChunk = 256
+
+type scorer interface {
+	score(statType string, value float64) float64
+}
+
+var scoreMethods = map[string]func(map[string]*SecondaryScore) scorer{
+	"minmax":     newMinMaxScorer,
+	"percentile": newPercentileScorer,
+	"absolute":   newAbsoluteScorer,
+}
+
+// secondaryMaxValues are the best possible 5-dot secondary values, i.e. a
+// stat that received every upgrade roll at the top of its range.
+var secondaryMaxValues = map[string]float64{
+	"Speed":             30,
+	"Offense":           228,
+	"Offense %":         2.81,
+	"Defense":           49,
+	"Defense %":         8.5,
+	"Health":            2140,
+	"Health %":          5.63,
+	"Protection":        4150,
+	"Protection %":      11.65,
+	"Potency %":         11.25,
+	"Tenacity %":        11.25,
+	"Critical Chance %": 11.25,
+}
+
+func scoringOptions(q url.Values) (ScoringOptions, error) {
+	opts := ScoringOptions{
+		Method:   *scoreMethod,
+		Disabled: *noScore,
+		Baseline: baseline,
+	}
+
+	if score := q.Get("score"); score != "" {
+		v, err := strconv.ParseBool(score)
+		if err != nil {
+			return opts, fmt.Errorf("bad score %q", score)
+		}
+		opts.Disabled = !v
+	}
+
+	return opts, nil
+}
+
+type baselineBound struct {
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+}
+
+// loadBaseline reads community min/max bounds from a JSON object keyed by
+// stat type, e.g. {"Speed": {"min": 3, "max": 30}}.
+func loadBaseline(path string) (map[string]*SecondaryScore, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var bounds map[string]baselineBound
+	if err := json.Unmarshal(data, &bounds); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	secondaryScoreMap := make(map[string]*SecondaryScore, len(bounds))
+	for statType, b := range bounds {
+		if b.Max < b.Min {
+			return nil, fmt.Errorf("%s: %s has max below min", path, statType)
+		}
+		secondaryScoreMap[statType] = &SecondaryScore{statType, b.Min, b.Max, nil}
+	}
+
+	return secondaryScoreMap, nil
+}
+
+// withBaseline swaps the population's min/max for the baseline's while
+// keeping the observed values, so percentile scoring is unaffected.
+func withBaseline(population, baseline map[string]*SecondaryScore) map[string]*SecondaryScore {
+	secondaryScoreMap := make(map[string]*SecondaryScore, len(baseline))
+	for statType, b := range baseline {
+		bound := &SecondaryScore{statType, b.Min, b.Max, nil}
+		if p, ok := population[statType]; ok {
+			bound.Values = p.Values
+		}
+		secondaryScoreMap[statType] = bound
+	}
+	return secondaryScoreMap
+}
+
+type minMaxScorer map[string]*SecondaryScore
+
+func newMinMaxScorer(secondaryScoreMap map[string]*SecondaryScore) scorer {
+	return minMaxScorer(secondaryScoreMap)
+}
+
+func (b minMaxScorer) score(statType string, value float64) float64 {
+	bound, ok := b[statType]
+	if !ok {
+		return 0
+	}
+	return minMaxScore(bound.Min, bound.Max, value)
+}
+
+func minMaxScore(lo, hi, value float64) float64 {
+	if hi == lo {
+		if value >= hi {
+			return 100
+		}
+		return 0
+	}
+
+	return math.Max(0, (value-lo)/(hi-lo)*100)
+}
+
+// percentileScorer scores a value by the fraction of the population that
+// rolled strictly lower, so the lowest value scores 0 and a unique highest
+// value scores 100. Tied values all share the rank of the lowest member of
+// the tie, so a tie for the top spot scores below 100.
+type percentileScorer map[string][]float64
+
+func newPercentileScorer(secondaryScoreMap map[string]*SecondaryScore) scorer {
+	p := make(percentileScorer, len(secondaryScoreMap))
+	for statType, bound := range secondaryScoreMap {
+		values := append([]float64(nil), bound.Values...)
+		sort.Float64s(values)
+		p[statType] = values
+	}
+	return p
+}
+
+func (p percentileScorer) score(statType string, value float64) float64 {
+	values := p[statType]
+	if len(values) == 0 {
+		return 0
+	}
+
+	if len(values) == 1 {
+		if value >= values[0] {
+			return 100
+		}
+		return 0
+	}
+
+	below := sort.SearchFloat64s(values, value)
+
+	return math.Min(100, float64(below)/float64(len(values)-1)*100)
+}
+
+// leaveOneOutScorer scores each value against the population without it,
+// so the best mod of a stat no longer sets the max it is measured against.
+// A value found in the population is taken to be the mod's own and one
+// occurrence of it is left out. Under minmax a value that tops the rest then
+// scores over 100, by how far it tops them.
+type leaveOneOutScorer struct {
+	percentile bool
+	values     percentileScorer
+}
+
+func newLeaveOneOutScorer(percentile bool) func(map[string]*SecondaryScore) scorer {
+	return func(secondaryScoreMap map[string]*SecondaryScore) scorer {
+		return leaveOneOutScorer{percentile, newPercentileScorer(secondaryScoreMap).(percentileScorer)}
+	}
+}
+
+func (l leaveOneOutScorer) score(statType string, value float64) float64 {
+	values := l.values[statType]
+	i := sort.SearchFloat64s(values, value)
+
+	if len(values) < 2 || i == len(values) || values[i] != value {
+		if l.percentile {
+			return l.values.score(statType, value)
+		}
+		if len(values) == 0 {
+			return 0
+		}
+		return minMaxScore(values[0], values[len(values)-1], value)
+	}
+
+	if l.percentile {
+		// Without the value itself, i values are still below it out of
+		// one fewer.
+		if len(values) == 2 {
+			if i == 0 && values[1] > value {
+				return 0
+			}
+			return 100
+		}
+		return math.Min(100, float64(i)/float64(len(values)-2)*100)
+	}
+
+	lo, hi := values[0], values[len(values)-1]
+	if value == lo {
+		lo = values[1]
+	}
+	if value == hi {
+		hi = values[len(values)-2]
+	}
+	return minMaxScore(lo, hi, value)
+}
+
+type absoluteScorer struct{}
+
+func newAbsoluteScorer(map[string]*SecondaryScore) scorer {
+	return absoluteScorer{}
+}
+
+func (absoluteScorer) score(statType string, value float64) float64 {
+	max, ok := secondaryMaxValues[statType]
+	if !ok {
+		return 0
+	}
+
+	return math.Min(100, math.Max(0, value/max*100))
+}
+
+type StatBounds struct {
+	Type  string  `json:"type"`
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Count int     `json:"count"`
+}
+
+// scoringBounds lists the min/max per secondary stat type that minmax
+// scoring uses, with how many qualifying values were observed for each.
+func scoringBounds(secondaryScoreMap map[string]*SecondaryScore, opts ScoringOptions) []StatBounds {
+	if opts.Baseline != nil {
+		secondaryScoreMap = withBaseline(secondaryScoreMap, opts.Baseline)
+	}
+
+	bounds := make([]StatBounds, 0, len(secondaryScoreMap))
+	for statType, b := range secondaryScoreMap {
+		bounds = append(bounds, StatBounds{statType, b.Min, b.Max, len(b.Values)})
+	}
+
+	sort.Slice(bounds, func(i, j int) bool {
+		return bounds[i].Type < bounds[j].Type
+	})
+
+	return bounds
+}
+
+// scoringPopulation builds the scoring bounds from the mods that qualify.
+// When fewer than -min-qualifying do, which is common on newer accounts and
+// would leave most stats scored against one or two mods, every mod is used
+// instead and allMods is set.
+func scoringPopulation(mods []*Mod, qualify func(*Mod) bool) (secondaryScoreMap map[string]*SecondaryScore, allMods bool) {
+	secondaryScoreMap = make(map[string]*SecondaryScore)
+	qualifying := 0
+	for _, m := range mods {
+		if qualify(m) {
+			addToBounds(secondaryScoreMap, m)
+			qualifying++
+		}
+	}
+
+	if qualifying >= *minQualifying {
+		return secondaryScoreMap, false
+	}
+
+	secondaryScoreMap = make(map[string]*SecondaryScore)
+	for _, m := range mods {
+		addToBounds(secondaryScoreMap, m)
+	}
+	return secondaryScoreMap, true
+}
+
+// sixDotBuckets splits bounds for -six-dot-bounds: the qualifying 6-dot
+// mods get bounds of their own and the other qualifying mods bounds without
+// them, since 6-dot secondaries roll higher. Both are nil, meaning score
+// everything against the combined bounds, unless each bucket has at least
+// -six-dot-min mods; a handful of 6-dots would otherwise score wildly.
+func sixDotBuckets(mods []*Mod, opts ScoringOptions) (six, rest map[string]*SecondaryScore) {
+	six = make(map[string]*SecondaryScore)
+	rest = make(map[string]*SecondaryScore)
+	sixCount, restCount := 0, 0
+
+	for _, m := range mods {
+		if !opts.qualify(m) {
+			continue
+		}
+		if m.Pips == 6 {
+			addToBounds(six, m)
+			sixCount++
+		} else {
+			addToBounds(rest, m)
+			restCount++
+		}
+	}
+
+	if sixCount < *sixDotMin || restCount < *sixDotMin {
+		return nil, nil
+	}
+
+	return six, rest
+}
+
+func scoreMods(mods []*Mod, secondaryScoreMap map[string]*SecondaryScore, opts ScoringOptions) {
+	if opts.Baseline != nil {
+		secondaryScoreMap = withBaseline(secondaryScoreMap, opts.Baseline)
+	}
+
+	newScorer := scoreMethods[opts.Method]
+
+	// A baseline fixes the bounds, and absolute scoring has none, so only
+	// population-based methods can leave a mod out of its own.
+	if *leaveOneOut && opts.Baseline == nil {
+		switch opts.Method {
+		case "minmax":
+			newScorer = newLeaveOneOutScorer(false)
+		case "percentile":
+			newScorer = newLeaveOneOutScorer(true)
+		}
+	}
+
+	sixDotScorer := newScorer(secondaryScoreMap)
+	otherScorer := sixDotScorer
+
+	// A baseline already fixes the bounds, so there is nothing to split.
+	if *sixDotBounds && opts.Baseline == nil {
+		if six, rest := sixDotBuckets(mods, opts); six != nil {
+			sixDotScorer, otherScorer = newScorer(six), newScorer(rest)
+		}
+	}
+
+	workers := *scoreWorkers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	// Each mod is scored on its own against bounds that no longer change,
+	// so the mods can be split between workers without any locking.
+	chunk := (len(mods) + workers - 1) / workers
+	if chunk < minScoreChunk {
+		chunk = minScoreChunk
+	}
+
+	var wg sync.WaitGroup
+	for start := 0; start < len(mods); start += chunk {
+		wg.Add(1)
+		go func(mods []*Mod) {
+			defer wg.Done()
+			roundScore := roundingModes[*scoreRounding]
+			aggregate := aggregates[*aggregateMode]
+			for _, m := range mods {
+				s := otherScorer
+				if m.Pips == 6 {
+					s = sixDotScorer
+				}
+
+				totalScore := 0
+				for _, stat := range m.SecondaryStats {
+					stat.Score = roundScore(s.score(stat.Type, stat.Value))
+					totalScore += stat.Score
+				}
+				m.TotalScore = aggregate(totalScore, len(m.SecondaryStats))
+				m.Grade = grade(m.TotalScore)
+				if *survivability {
+					m.Survivability = survivabilityScore(m)
+				}
+			}
+		}(mods[start:min(start+chunk, len(mods))])
+	}
+	wg.Wait()
+}
+
+// survivabilityStats are the secondaries that add to a character's
+// effective HP.
+var survivabilityStats = map[string]bool{
+	"Health":       true,
+	"Health %":     true,
+	"Protection":   true,
+	"Protection %": true,
+}
+
+// survivabilityScore sums the scores of a mod's health and protection
+// secondaries. Percent values depend on the character's base stats, so
+// scores rather than raw values are what can be added up.
+func survivabilityScore(m *Mod) int {
+	total := 0
+	for _, stat := range m.SecondaryStats {
+		if survivabilityStats[stat.Type] {
+			total += stat.Score
+		}
+	}
+	return total
+}
+
+// validScoreScales are the -score-scale values offered. Scores are always
+// computed out of 100 per secondary; the scale only changes what is shown.
+var validScoreScales = map[float64]bool{100: true, 10: true, 1: true}
+
+func presentScore(score int) float64 {
+	return presentValue(float64(score))
+}
+
+// presentValue scales any score-like value, such as a loadout's total or an
+// expected gain, from out of 100 per secondary to -score-scale.
+func presentValue(v float64) float64 {
+	return v * *scoreScale / 100
+}
+
+// unpresentValue is presentValue's inverse, for a score given on the
+// -score-scale scale, e.g. in ?minscore=.
+func unpresentValue(v float64) float64 {
+	return v * 100 / *scoreScale
+}
+
+func formatScore(score int) string {
+	return strconv.FormatFloat(presentScore(score), 'f', -1, 64)
+}
+
+func (s SecondaryStat) MarshalJSON() ([]byte, error) {
+	type secondaryStat SecondaryStat
+	return json.Marshal(struct {
+		secondaryStat
+		Score float64 `json:"score"`
+	}{secondaryStat(s), presentScore(s.Score)})
+}
+
+func (m Mod) MarshalJSON() ([]byte, error) {
+	type mod Mod
+	return json.Marshal(struct {
+		mod
+		TotalScore    float64 `json:"totalScore"`
+		Survivability float64 `json:"survivability,omitempty"`
+	}{mod(m), presentScore(m.TotalScore), presentScore(m.Survivability)})
+}
+
+func (c ScoreChange) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Uid  string  `json:"uid"`
+		From float64 `json:"from"`
+		To   float64 `json:"to"`
+	}{c.Uid, presentScore(c.From), presentScore(c.To)})
+}
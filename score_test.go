@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// benchmarkMods are n qualifying mods with four random secondaries each,
+// the same on every call.
+func benchmarkMods(n int) []*Mod {
+	r := rand.New(rand.NewSource(1))
+	statTypes := []string{"Speed", "Offense", "Offense %", "Defense", "Health", "Health %", "Protection %", "Potency %", "Critical Chance %"}
+
+	mods := make([]*Mod, n)
+	for i := range mods {
+		m := &Mod{Uid: fmt.Sprintf("b-%d", i), Pips: 5, Level: 15}
+		for _, j := range r.Perm(len(statTypes))[:4] {
+			m.SecondaryStats = append(m.SecondaryStats, &SecondaryStat{Stat{statTypes[j], float64(r.Intn(30) + 1), 0}, 0})
+		}
+		m.RevealedSecondaries = len(m.SecondaryStats)
+		mods[i] = m
+	}
+	return mods
+}
+
+// benchmarkScoreMods scores 5000 mods with method, whatever -score-method
+// says.
+func benchmarkScoreMods(b *testing.B, method string) {
+	mods := benchmarkMods(5000)
+	bounds, _ := scoringPopulation(mods, qualifies)
+
+	opts, err := scoringOptions(nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	opts.Method = method
+
+	b.ReportAllocs()
+	for b.Loop() {
+		scoreMods(mods, bounds, opts)
+	}
+}
+
+func BenchmarkScoreModsOneWorker(b *testing.B) {
+	saved := *scoreWorkers
+	*scoreWorkers = 1
+	defer func() { *scoreWorkers = saved }()
+
+	benchmarkScoreMods(b, "minmax")
+}
+
+// BenchmarkScoreModsWorkers scores with one worker per GOMAXPROCS, the
+// -score-workers default.
+func BenchmarkScoreModsWorkers(b *testing.B) {
+	benchmarkScoreMods(b, "minmax")
+}
+
+// benchmarkLeaveOneOut is benchmarkScoreMods under -leave-one-out, to set
+// against the same method without it.
+func benchmarkLeaveOneOut(b *testing.B, method string) {
+	saved := *leaveOneOut
+	*leaveOneOut = true
+	defer func() { *leaveOneOut = saved }()
+
+	benchmarkScoreMods(b, method)
+}
+
+func BenchmarkScoreModsMinMax(b *testing.B)                { benchmarkScoreMods(b, "minmax") }
+func BenchmarkScoreModsMinMaxLeaveOneOut(b *testing.B)     { benchmarkLeaveOneOut(b, "minmax") }
+func BenchmarkScoreModsPercentile(b *testing.B)            { benchmarkScoreMods(b, "percentile") }
+func BenchmarkScoreModsPercentileLeaveOneOut(b *testing.B) { benchmarkLeaveOneOut(b, "percentile") }
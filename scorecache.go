@@ -0,0 +1,85 @@
+package main
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+)
+
+type scoredEntry struct {
+	key string
+	raw *ScrapeResult
+	res *ScrapeResult
+}
+
+// scoredCache is a small LRU of scored results keyed by user and scoring
+// options. Each entry remembers the raw scrape it was scored from, so a
+// refreshed scrape makes the old scores miss instead of going stale.
+type scoredCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+var scored = newScoredCache(0)
+
+func newScoredCache(size int) *scoredCache {
+	return &scoredCache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// scoredKey is the cache key for user's mods scored with opts, and false if
+// they can't be cached because opts.Qualifies has no QualifyRule naming it.
+func scoredKey(user string, opts ScoringOptions) (string, bool) {
+	if opts.Qualifies != nil && opts.QualifyRule == "" {
+		return "", false
+	}
+	return fmt.Sprintf("%s|%s|%t|%t|%s", user, opts.Method, opts.Disabled, opts.Baseline != nil, opts.QualifyRule), true
+}
+
+func (c *scoredCache) get(key string, raw *ScrapeResult) (*ScrapeResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	e := el.Value.(*scoredEntry)
+	if e.raw != raw {
+		c.order.Remove(el)
+		delete(c.entries, key)
+		return nil, false
+	}
+
+	c.order.MoveToFront(el)
+	return e.res, true
+}
+
+func (c *scoredCache) put(key string, raw, res *ScrapeResult) {
+	if c.size <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		el.Value = &scoredEntry{key, raw, res}
+		c.order.MoveToFront(el)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&scoredEntry{key, raw, res})
+
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*scoredEntry).key)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// Selectors are the CSS selectors the scraper reads swgoh.gg's mods pages
+// with. All but Mod and Pagination match within a mod element; StatLabel,
+// StatValue and ContextualValue match within a primary or secondary stat.
+type Selectors struct {
+	Mod             string `json:"mod"`
+	Pagination      string `json:"pagination"`
+	Image           string `json:"image"`
+	Pip             string `json:"pip"`
+	Level           string `json:"level"`
+	UpgradeCost     string `json:"upgradeCost"`
+	Portrait        string `json:"portrait"`
+	Locked          string `json:"locked"`
+	Primary         string `json:"primary"`
+	Secondary       string `json:"secondary"`
+	StatLabel       string `json:"statLabel"`
+	StatValue       string `json:"statValue"`
+	ContextualValue string `json:"contextualValue"`
+}
+
+var defaultSelectors = Selectors{
+	Mod:             ".collection-mod",
+	Pagination:      ".pull-right .pagination li a",
+	Image:           ".statmod-img",
+	Pip:             ".statmod-pip",
+	Level:           ".statmod-level",
+	UpgradeCost:     ".statmod-upgrade-cost",
+	Portrait:        ".char-portrait",
+	Locked:          ".statmod-locked",
+	Primary:         ".statmod-stats-1",
+	Secondary:       ".statmod-stats-2 .statmod-stat",
+	StatLabel:       ".statmod-stat-label",
+	StatValue:       ".statmod-stat-value",
+	ContextualValue: ".statmod-stat-value-char",
+}
+
+// selectors are the ones in use: the defaults, then any -selectors file,
+// then the individual selector flags given on the command line.
+var selectors = defaultSelectors
+
+// loadSelectors reads a JSON object of selectors to change, e.g.
+// {"level": ".mod-level"}, over the defaults; fields it leaves out keep
+// their default.
+func loadSelectors(path string) (Selectors, error) {
+	s := defaultSelectors
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return s, err
+	}
+
+	if err := json.Unmarshal(data, &s); err != nil {
+		return s, fmt.Errorf("%s: %v", path, err)
+	}
+
+	return s, nil
+}
+
+// applySelectorFlags lets -mod-selector, -stat-label-selector and
+// -locked-selector, when given, win over a -selectors file.
+func applySelectorFlags() {
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "mod-selector":
+			selectors.Mod = *modSelector
+		case "stat-label-selector":
+			selectors.StatLabel = *statLabelSelector
+		case "locked-selector":
+			selectors.Locked = *lockedSelector
+		}
+	})
+}
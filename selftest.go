@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+//go:embed static/selftest/mods.html
+var selftestHTML []byte
+
+//go:embed static/selftest/expected.json
+var selftestExpected []byte
+
+// selftestMod is what the fixture's mods must parse and score to.
+type selftestMod struct {
+	Uid        string `json:"uid"`
+	Slot       string `json:"slot"`
+	Set        string `json:"set"`
+	Level      int    `json:"level"`
+	Pips       int    `json:"pips"`
+	Character  string `json:"character"`
+	Locked     bool   `json:"locked"`
+	Primary    Stat   `json:"primary"`
+	Secondary  []Stat `json:"secondaries"`
+	Scores     []int  `json:"scores"`
+	TotalScore int    `json:"totalScore"`
+}
+
+func toSelftestMod(m *Mod) selftestMod {
+	st := selftestMod{m.Uid, m.Slot, m.Set, m.Level, m.Pips, m.CharacterName, m.Locked, m.PrimaryStat.Stat, nil, nil, m.TotalScore}
+	for _, s := range m.SecondaryStats {
+		st.Secondary = append(st.Secondary, s.Stat)
+		st.Scores = append(st.Scores, s.Score)
+	}
+	return st
+}
+
+// runSelftest parses and scores the bundled fixture page, with minmax
+// scoring, and reports to out whether every mod comes out as expected. It
+// needs no network, but the expected scores assume the default scoring
+// flags.
+func runSelftest(logger *slog.Logger, out io.Writer) bool {
+	var want []selftestMod
+	if err := json.Unmarshal(selftestExpected, &want); err != nil {
+		fmt.Fprintf(out, "selftest: FAIL (bad expected output: %v)\n", err)
+		return false
+	}
+
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(selftestHTML))
+
+	if err != nil {
+		fmt.Fprintf(out, "selftest: FAIL (%v)\n", err)
+		return false
+	}
+
+	if pages, err := getPageCount(logger, doc); err != nil || pages != 1 {
+		fmt.Fprintf(out, "selftest: FAIL (pagination: %d pages, %v)\n", pages, err)
+		return false
+	}
+
+	modChan := make(chan *Mod)
+	var mods []*Mod
+	done := make(chan struct{})
+	go func() {
+		for m := range modChan {
+			mods = append(mods, m)
+		}
+		close(done)
+	}()
+
+	_, err = parsePage(logger, doc, 1, modChan)
+	close(modChan)
+	<-done
+
+	if err != nil {
+		fmt.Fprintf(out, "selftest: FAIL (%v)\n", err)
+		return false
+	}
+
+	opts := ScoringOptions{Method: "minmax"}
+	bounds, _ := scoringPopulation(mods, opts.qualify)
+	scoreMods(mods, bounds, opts)
+
+	ok := len(mods) == len(want)
+	if !ok {
+		fmt.Fprintf(out, "selftest: parsed %d mods, want %d\n", len(mods), len(want))
+	}
+
+	for i := range min(len(mods), len(want)) {
+		got := toSelftestMod(mods[i])
+		if !selftestEqual(got, want[i]) {
+			gotJSON, _ := json.Marshal(got)
+			wantJSON, _ := json.Marshal(want[i])
+			fmt.Fprintf(out, "selftest: mod %d\n  got  %s\n  want %s\n", i, gotJSON, wantJSON)
+			ok = false
+		}
+	}
+
+	if ok {
+		fmt.Fprintf(out, "selftest: ok (%d mods)\n", len(mods))
+	} else {
+		fmt.Fprintln(out, "selftest: FAIL")
+	}
+	return ok
+}
+
+func selftestEqual(a, b selftestMod) bool {
+	return a.Uid == b.Uid && a.Slot == b.Slot && a.Set == b.Set && a.Level == b.Level &&
+		a.Pips == b.Pips && a.Character == b.Character && a.Locked == b.Locked &&
+		a.Primary == b.Primary && slices.Equal(a.Secondary, b.Secondary) &&
+		slices.Equal(a.Scores, b.Scores) && a.TotalScore == b.TotalScore
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+type snapshot struct {
+	Taken time.Time
+	Mods  []*Mod
+}
+
+// storedScrape is a raw scrape and when the scrape cache stored it.
+type storedScrape struct {
+	taken time.Time
+	res   *ScrapeResult
+}
+
+// userScrapes are a user's latest complete scrape and the distinct one
+// before it. seq numbers the latest put, to find the oldest user even when
+// the clock gives two the same time.
+type userScrapes struct {
+	prev, cur storedScrape
+	seq       uint64
+}
+
+// snapshotStore keeps the last two complete scrapes of each user in memory
+// so /diff can compare them. The scrape cache puts every scrape it stores,
+// and the same result put again, as a cache hit would, changes nothing.
+// Past max users, the user whose latest scrape is oldest is forgotten; 0
+// keeps every user.
+type snapshotStore struct {
+	mu    sync.Mutex
+	max   int
+	users map[string]*userScrapes
+	count uint64
+}
+
+var snapshots = newSnapshotStore(0)
+
+func newSnapshotStore(max int) *snapshotStore {
+	return &snapshotStore{max: max, users: make(map[string]*userScrapes)}
+}
+
+// previous returns the complete scrape of user before the latest one.
+func (s *snapshotStore) previous(user string) (storedScrape, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[user]
+	if !ok || u.prev.res == nil {
+		return storedScrape{}, false
+	}
+	return u.prev, true
+}
+
+func (s *snapshotStore) put(user string, res *ScrapeResult) {
+	if res.Partial {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[user]
+	if !ok {
+		u = new(userScrapes)
+		s.users[user] = u
+	}
+	if u.cur.res == res {
+		return
+	}
+
+	u.prev = u.cur
+	u.cur = storedScrape{time.Now(), res}
+
+	s.count++
+	u.seq = s.count
+
+	for s.max > 0 && len(s.users) > s.max {
+		oldest := user
+		for name, other := range s.users {
+			if other.seq < s.users[oldest].seq {
+				oldest = name
+			}
+		}
+		delete(s.users, oldest)
+	}
+}
+
+type ModMove struct {
+	Uid  string `json:"uid"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type ScoreChange struct {
+	Uid  string `json:"uid"`
+	From int    `json:"from"`
+	To   int    `json:"to"`
+}
+
+type ModDiff struct {
+	Previous     time.Time     `json:"previous"`
+	Current      time.Time     `json:"current"`
+	Added        []*Mod        `json:"added"`
+	Removed      []*Mod        `json:"removed"`
+	Moved        []ModMove     `json:"moved"`
+	ScoreChanges []ScoreChange `json:"scoreChanges"`
+}
+
+func diffMods(prev, cur snapshot) ModDiff {
+	diff := ModDiff{Previous: prev.Taken, Current: cur.Taken}
+
+	prevByUid := make(map[string]*Mod, len(prev.Mods))
+	for _, m := range prev.Mods {
+		prevByUid[m.Uid] = m
+	}
+
+	curByUid := make(map[string]bool, len(cur.Mods))
+	for _, m := range cur.Mods {
+		curByUid[m.Uid] = true
+
+		old, ok := prevByUid[m.Uid]
+		if !ok {
+			diff.Added = append(diff.Added, m)
+			continue
+		}
+
+		if old.CharacterName != m.CharacterName {
+			diff.Moved = append(diff.Moved, ModMove{m.Uid, old.CharacterName, m.CharacterName})
+		}
+		if old.TotalScore != m.TotalScore {
+			diff.ScoreChanges = append(diff.ScoreChanges, ScoreChange{m.Uid, old.TotalScore, m.TotalScore})
+		}
+	}
+
+	for _, m := range prev.Mods {
+		if !curByUid[m.Uid] {
+			diff.Removed = append(diff.Removed, m)
+		}
+	}
+
+	return diff
+}
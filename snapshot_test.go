@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSnapshotStoreForgetsOldest(t *testing.T) {
+	s := newSnapshotStore(2)
+
+	for _, user := range []string{"alice", "bob", "carol"} {
+		s.put(user, &ScrapeResult{})
+		s.put(user, &ScrapeResult{})
+	}
+
+	if _, ok := s.previous("alice"); ok {
+		t.Error("alice's scrapes, the oldest, are still kept")
+	}
+	for _, user := range []string{"bob", "carol"} {
+		if _, ok := s.previous(user); !ok {
+			t.Errorf("%s's scrapes were forgotten", user)
+		}
+	}
+}
+
+func TestSnapshotStoreRotatesOnNewScrape(t *testing.T) {
+	s := newSnapshotStore(0)
+	first, second := &ScrapeResult{}, &ScrapeResult{}
+
+	s.put("alice", first)
+	s.put("alice", first)
+	if _, ok := s.previous("alice"); ok {
+		t.Error("the same scrape put twice left a previous one")
+	}
+
+	s.put("alice", second)
+	s.put("alice", second)
+	if prev, ok := s.previous("alice"); !ok || prev.res != first {
+		t.Errorf("previous scrape is %p, want the first %p", prev.res, first)
+	}
+}
+
+// TestDiffAfterNewScrape checks /diff compares against the scrape before
+// the latest, however many cache hits came between.
+func TestDiffAfterNewScrape(t *testing.T) {
+	// The second version of the page swaps a mod for one not seen before.
+	pages := [][]byte{selftestHTML, bytes.Replace(selftestHTML, []byte(`data-id="st-01"`), []byte(`data-id="st-new"`), 1)}
+	var version atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(pages[version.Load()])
+	}))
+	defer srv.Close()
+
+	savedURL, savedScrapes, savedScored, savedSnapshots := *baseURL, scrapes, scored, snapshots
+	defer func() { *baseURL, scrapes, scored, snapshots = savedURL, savedScrapes, savedScored, savedSnapshots }()
+	*baseURL = srv.URL
+	snapshots = newSnapshotStore(0)
+	scrapes = newScrapeCache(time.Hour, time.Hour, fetchMods)
+	scrapes.stored = snapshots.put
+	scored = newScoredCache(8)
+
+	get := func(handler http.HandlerFunc, url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodGet, url, nil))
+		return w
+	}
+
+	if w := get(apiDiff, "/diff?u=alice"); w.Code != http.StatusNotFound {
+		t.Fatalf("first /diff: got status %d, want %d", w.Code, http.StatusNotFound)
+	}
+	if w := get(apiMods, "/api/mods?u=alice"); w.Code != http.StatusOK {
+		t.Fatalf("GET /api/mods: got status %d", w.Code)
+	}
+
+	version.Store(1)
+	scrapes.refresh(slog.Default(), "alice")
+
+	if w := get(apiMods, "/api/mods?u=alice"); w.Code != http.StatusOK {
+		t.Fatalf("GET /api/mods after the new scrape: got status %d", w.Code)
+	}
+
+	w := get(apiDiff, "/diff?u=alice")
+	if w.Code != http.StatusOK {
+		t.Fatalf("/diff: got status %d: %s", w.Code, w.Body)
+	}
+
+	var diff struct {
+		Added, Removed []struct{ Uid string }
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &diff); err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.Added) != 1 || diff.Added[0].Uid != "st-new" {
+		t.Errorf("added %v, want st-new", diff.Added)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0].Uid != "st-01" {
+		t.Errorf("removed %v, want st-01", diff.Removed)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+const (
+	sparklineBuckets = 8
+	sparklineHeight  = 16
+	sparklineBarWide = 4
+)
+
+// Sparkline is how the secondary values of one stat type spread between
+// the lowest and highest seen, in equal-width buckets.
+type Sparkline struct {
+	Type   string
+	Min    float64
+	Max    float64
+	Counts []int
+}
+
+type sparkBar struct {
+	X, Y, Width, Height, Count int
+}
+
+// Width and Height size the sparkline's SVG.
+func (s Sparkline) Width() int  { return len(s.Counts) * sparklineBarWide }
+func (s Sparkline) Height() int { return sparklineHeight }
+
+// Bars lays the buckets out as SVG rects, the fullest bucket filling the
+// height and any non-empty one at least a pixel so it stays visible.
+func (s Sparkline) Bars() []sparkBar {
+	peak := 0
+	for _, c := range s.Counts {
+		peak = max(peak, c)
+	}
+
+	bars := make([]sparkBar, len(s.Counts))
+	for i, c := range s.Counts {
+		h := 0
+		if peak > 0 && c > 0 {
+			h = max(1, c*sparklineHeight/peak)
+		}
+		bars[i] = sparkBar{i * sparklineBarWide, sparklineHeight - h, sparklineBarWide - 1, h, c}
+	}
+	return bars
+}
+
+// sparklines buckets the secondary values of mods per stat type.
+func sparklines(mods []*Mod) []Sparkline {
+	values := make(map[string][]float64)
+	for _, m := range mods {
+		for _, s := range m.SecondaryStats {
+			values[s.Type] = append(values[s.Type], s.Value)
+		}
+	}
+
+	lines := make([]Sparkline, 0, len(values))
+	for statType, vs := range values {
+		line := Sparkline{statType, math.Inf(1), math.Inf(-1), make([]int, sparklineBuckets)}
+		for _, v := range vs {
+			line.Min = math.Min(line.Min, v)
+			line.Max = math.Max(line.Max, v)
+		}
+
+		span := line.Max - line.Min
+		for _, v := range vs {
+			i := 0
+			if span > 0 {
+				i = min(int((v-line.Min)/span*sparklineBuckets), sparklineBuckets-1)
+			}
+			line.Counts[i]++
+		}
+
+		lines = append(lines, line)
+	}
+
+	sort.Slice(lines, func(i, j int) bool { return lines[i].Type < lines[j].Type })
+	return lines
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+type StatTotals struct {
+	Flat    map[string]float64 `json:"flat"`
+	Percent map[string]float64 `json:"percent"`
+}
+
+func (t StatTotals) add(stat Stat) {
+	if strings.HasSuffix(stat.Type, "%") {
+		t.Percent[stat.Type] += stat.Value
+	} else {
+		t.Flat[stat.Type] += stat.Value
+	}
+}
+
+func equippedTotals(mods []*Mod) StatTotals {
+	totals := StatTotals{
+		Flat:    make(map[string]float64),
+		Percent: make(map[string]float64),
+	}
+
+	for _, m := range mods {
+		if !m.Equipped {
+			continue
+		}
+
+		totals.add(m.PrimaryStat.Stat)
+		for _, s := range m.SecondaryStats {
+			totals.add(s.Stat)
+		}
+	}
+
+	return totals
+}
+
+// ModSummary is the collection-wide view of a scrape without its mods, for
+// dashboards that only track quality over time.
+type ModSummary struct {
+	Count        int            `json:"count"`
+	Unassigned   int            `json:"unassigned"`
+	AverageScore float64        `json:"averageScore"`
+	MaxScore     float64        `json:"maxScore"`
+	PipCounts    map[int]int    `json:"pipCounts"`
+	GradeCounts  map[string]int `json:"gradeCounts,omitempty"`
+	Bounds       []StatBounds   `json:"bounds"`
+}
+
+// summarizeMods reuses the page summary for mods and adds how many got each
+// grade and the bounds they were scored against.
+func summarizeMods(mods []*Mod, secondaryScoreMap map[string]*SecondaryScore, opts ScoringOptions) ModSummary {
+	data := newModData(mods)
+
+	summary := ModSummary{
+		Count:        data.TotalCount,
+		Unassigned:   data.Unassigned,
+		AverageScore: data.AverageScore,
+		MaxScore:     presentScore(data.MaxScore),
+		PipCounts:    data.PipCounts,
+		Bounds:       scoringBounds(secondaryScoreMap, opts),
+	}
+
+	for _, m := range mods {
+		if m.Grade == "" {
+			continue
+		}
+		if summary.GradeCounts == nil {
+			summary.GradeCounts = make(map[string]int)
+		}
+		summary.GradeCounts[m.Grade]++
+	}
+
+	return summary
+}
+
+type StatTypeCount struct {
+	Type    string `json:"type"`
+	Percent bool   `json:"percent"`
+	Count   int    `json:"count"`
+}
+
+// ObservedStats lists the stat types that appear in a collection, for
+// building filters from real data.
+type ObservedStats struct {
+	Primaries   []StatTypeCount `json:"primaries"`
+	Secondaries []StatTypeCount `json:"secondaries"`
+}
+
+func countStatTypes(counts map[string]int) []StatTypeCount {
+	types := make([]StatTypeCount, 0, len(counts))
+	for t, n := range counts {
+		types = append(types, StatTypeCount{t, strings.HasSuffix(t, "%"), n})
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i].Type < types[j].Type })
+	return types
+}
+
+// observedStats counts how many mods have each primary and secondary type.
+func observedStats(mods []*Mod) ObservedStats {
+	primaries := make(map[string]int)
+	secondaries := make(map[string]int)
+
+	for _, m := range mods {
+		if m.PrimaryStat.Type != "" {
+			primaries[m.PrimaryStat.Type]++
+		}
+		for _, s := range m.SecondaryStats {
+			secondaries[s.Type]++
+		}
+	}
+
+	return ObservedStats{countStatTypes(primaries), countStatTypes(secondaries)}
+}
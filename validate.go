@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// validateScrape fetches the first mods page for user and reports to out
+// how well the scraper still understands swgoh.gg's markup. It returns
+// false when the page looks broken: no mods found, mods that fail to
+// parse, or pagination that can't be read. Mods without a character are
+// counted but allowed, since unassigned mods have none.
+func validateScrape(logger *slog.Logger, out io.Writer, user string) bool {
+	doc, err := fetchPage(withLogger(context.Background(), logger), user, 1)
+
+	if err != nil {
+		fmt.Fprintf(out, "fetch: FAIL (%v)\n", err)
+		return false
+	}
+
+	ok := true
+
+	pageCount, err := getPageCount(logger, doc)
+	if err != nil {
+		fmt.Fprintf(out, "pagination: FAIL (%v)\n", err)
+		ok = false
+	} else {
+		fmt.Fprintf(out, "pagination: ok (%d pages)\n", pageCount)
+	}
+
+	var found, failed, noSet, noSlot, noCharacter, noPrimary int
+
+	doc.Find(selectors.Mod).Each(func(i int, s *goquery.Selection) {
+		found++
+
+		mod, err := parseMod(s)
+		if err != nil {
+			failed++
+			fmt.Fprintf(out, "  %v\n", err)
+			return
+		}
+
+		if mod.Set == "" {
+			noSet++
+		}
+		if mod.Slot == "" {
+			noSlot++
+		}
+		if mod.CharacterName == "" {
+			noCharacter++
+		}
+		if mod.PrimaryStat.Type == "" {
+			noPrimary++
+		}
+	})
+
+	fmt.Fprintf(out, "mods: %d found, %d parsed, %d failed\n", found, found-failed, failed)
+	fmt.Fprintf(out, "missing: set %d, slot %d, primary %d, character %d\n", noSet, noSlot, noPrimary, noCharacter)
+
+	if found == 0 || failed > 0 || noSet > 0 || noSlot > 0 || noPrimary > 0 {
+		ok = false
+	}
+
+	if ok {
+		fmt.Fprintln(out, "result: ok")
+	} else {
+		fmt.Fprintln(out, "result: FAIL")
+	}
+
+	return ok
+}
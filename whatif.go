@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const maxSecondaries = 4
+
+type WhatIf struct {
+	TotalScore     int              `json:"totalScore"`
+	Grade          string           `json:"grade"`
+	SecondaryStats []*SecondaryStat `json:"secondaryStats"`
+}
+
+// parseWhatIfStats reads hypothetical secondaries given as "type:value",
+// e.g. "Speed:15" or "Offense%:1.5".
+func parseWhatIfStats(raw []string) ([]*SecondaryStat, error) {
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("missing stat parameter")
+	}
+	if len(raw) > maxSecondaries {
+		return nil, fmt.Errorf("a mod has at most %d secondaries", maxSecondaries)
+	}
+
+	stats := make([]*SecondaryStat, 0, len(raw))
+	seen := make(map[string]bool, len(raw))
+	for _, r := range raw {
+		i := strings.LastIndex(r, ":")
+		if i < 0 {
+			return nil, fmt.Errorf("bad stat %q, want type:value", r)
+		}
+
+		statType := normalizeStatType(r[:i])
+		if _, ok := secondaryMaxValues[statType]; !ok {
+			return nil, fmt.Errorf("unknown secondary stat %q", statType)
+		}
+		if seen[statType] {
+			return nil, fmt.Errorf("duplicate secondary stat %q", statType)
+		}
+		seen[statType] = true
+
+		value, err := strconv.ParseFloat(strings.TrimSpace(r[i+1:]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad stat value %q", r[i+1:])
+		}
+
+		stats = append(stats, &SecondaryStat{Stat{statType, value, 0}, 0})
+	}
+
+	return stats, nil
+}
+
+// scoreWhatIf scores hypothetical secondaries against the bounds of an
+// existing collection, the same way that collection's own mods are scored.
+func scoreWhatIf(stats []*SecondaryStat, secondaryScoreMap map[string]*SecondaryScore, opts ScoringOptions) WhatIf {
+	m := &Mod{SecondaryStats: stats}
+	scoreMods([]*Mod{m}, secondaryScoreMap, opts)
+	return WhatIf{m.TotalScore, m.Grade, m.SecondaryStats}
+}
+
+func (w WhatIf) MarshalJSON() ([]byte, error) {
+	type whatIf WhatIf
+	return json.Marshal(struct {
+		whatIf
+		TotalScore float64 `json:"totalScore"`
+	}{whatIf(w), presentScore(w.TotalScore)})
+}